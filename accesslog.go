@@ -0,0 +1,89 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// accessLog, if not nil, receives a line for each request served.
+var accessLog *log.Logger
+
+// openAccessLog sets accessLog to write to the file named name,
+// or to standard output if name is "-".
+func openAccessLog(name string) error {
+	var w io.Writer = os.Stdout
+	if name != "-" {
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		w = f
+	}
+	accessLog = log.New(w, "", 0)
+	return nil
+}
+
+// A loggingWriter is an http.ResponseWriter that records
+// the status and size of the response written through it.
+type loggingWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *loggingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *loggingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// logAccess returns a handler that calls h and then logs the request
+// to accessLog in the Apache Combined Log Format.
+func logAccess(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		lw := &loggingWriter{ResponseWriter: w}
+		h.ServeHTTP(lw, req)
+
+		host := "-"
+		if ip := clientIP(req); ip != nil {
+			host = ip.String()
+		}
+		if lw.status == 0 {
+			lw.status = http.StatusOK
+		}
+		size := "-"
+		if lw.size > 0 {
+			size = strconv.Itoa(lw.size)
+		}
+		accessLog.Printf("%s - - [%s] %q %d %s %q %q",
+			host, start.Format("02/Jan/2006:15:04:05 -0700"),
+			req.Method+" "+req.RequestURI+" "+req.Proto,
+			lw.status, size, orDash(req.Referer()), orDash(req.UserAgent()))
+	})
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,46 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	defer func(old *log.Logger) { accessLog = old }(accessLog)
+	var buf bytes.Buffer
+	accessLog = log.New(&buf, "", 0)
+	h := logAccess(http.HandlerFunc(redirect))
+
+	tests := []struct {
+		url    string
+		status string
+	}{
+		{"http://rsc.io/x86?go-get=1", "200"},
+		{"http://rsc.io/pdf?go-get=1", "404"},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		req := httptest.NewRequest("GET", tt.url, nil)
+		req.Header.Set("Referer", "https://example.com/")
+		req.Header.Set("User-Agent", "Go-http-client/1.1")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		line := strings.TrimSuffix(buf.String(), "\n")
+		re := regexp.MustCompile(`^192\.0\.2\.1 - - \[\d\d/\w{3}/\d{4}:\d\d:\d\d:\d\d [-+]\d{4}\] "GET ` +
+			regexp.QuoteMeta(req.RequestURI) + ` HTTP/1\.1" ` + tt.status + ` \d+ "https://example\.com/" "Go-http-client/1\.1"$`)
+		if !re.MatchString(line) {
+			t.Errorf("%s: access log line %q, want Combined Log Format with status %s", tt.url, line, tt.status)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// serveAdmin serves the admin API on addr until ctx is done.
+// It is kept off the public mux so that it can be bound to a private address.
+func serveAdmin(ctx context.Context, addr string) {
+	log.Printf("Serving admin API on %s", addr)
+	srv := newAdminServer(addr)
+	if err := runServer(ctx, srv, srv.ListenAndServe); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newAdminServer returns the admin API server for addr, with the same
+// timeouts and header limit as the public servers of newServer.
+func newAdminServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:           addr,
+		Handler:        adminHandler(),
+		ReadTimeout:    *readTimeout,
+		WriteTimeout:   *writeTimeout,
+		IdleTimeout:    *idleTimeout,
+		MaxHeaderBytes: *maxHeaderBytes,
+		ErrorLog:       requestLog,
+	}
+}
+
+// checkAdminAddr checks that the -admin-addr address is a loopback
+// address, such as localhost:8081, unless -admin-token is set:
+// anyone who can reach the admin API can change what is served.
+func checkAdminAddr(addr string) error {
+	if err := checkAddr(addr); err != nil {
+		return err
+	}
+	if *adminToken != "" {
+		return nil
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("-admin-addr %s is not a loopback address: set -admin-token to serve the admin API on it", addr)
+	}
+	return nil
+}
+
+// adminHandler returns the handler for the admin API endpoints.
+func adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/docsite", adminDocsite)
+	mux.HandleFunc("/mappings", adminMappings)
+	mux.HandleFunc("/metrics", adminMetrics)
+	mux.HandleFunc("/reload", adminReload)
+	return adminAuth(mux)
+}
+
+// adminAuth returns a handler that calls h only for requests carrying
+// the -admin-token as a bearer token, if one is set.
+// Other requests get 401 Unauthorized.
+func adminAuth(h http.Handler) http.Handler {
+	if *adminToken == "" {
+		return h
+	}
+	want := []byte("Bearer " + *adminToken)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// An adminMapping is the form in which /mappings lists a couple.
+type adminMapping struct {
+	Import   string `json:"import"`
+	Repo     string `json:"repo"`
+	VCS      string `json:"vcs"`
+	Subdir   string `json:"subdir,omitempty"`
+	Ref      string `json:"ref,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+	Source   string `json:"source,omitempty"`
+
+	Hosts map[string]string `json:"hosts,omitempty"`
+}
+
+// adminMappings lists the current couples as JSON, sorted by import path.
+func adminMappings(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	list := []adminMapping{}
+	for importPath, c := range mappings.All() {
+		list = append(list, adminMapping{
+			Import:   strings.TrimSuffix(importPath, "/"),
+			Repo:     c.Repo,
+			VCS:      mappings.VCSFor(importPath, c),
+			Subdir:   c.Subdir,
+			Ref:      c.Ref,
+			Disabled: c.Disabled,
+			Source:   c.Source,
+			Hosts:    c.Hosts,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Import < list[j].Import })
+	js, err := json.MarshalIndent(list, "", "\t")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(js, '\n'))
+}
+
+// adminReload rereads the config file on POST, as on SIGHUP.
+func adminReload(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if filePath == "" {
+		http.Error(w, "no config file to reload", http.StatusConflict)
+		return
+	}
+	log.Printf("Reloading %s", filePath)
+	if err := reloadConfig(); err != nil {
+		log.Printf("reload failed, keeping the previous config: %v", err)
+		http.Error(w, "reload failed, keeping the previous config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "reloaded %d mappings\n", mappings.Len())
+}
+
+// adminDocsite reports the documentation host on GET
+// and replaces it with the request body on PUT or POST.
+func adminDocsite(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET", "HEAD":
+		fmt.Fprintln(w, mappings.DocsHost())
+	case "PUT", "POST":
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, 1024))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		host := strings.TrimSpace(string(body))
+		if !validDocsHost(host) {
+			http.Error(w, "body must be a host, like pkg.go.dev, or an http or https URL", http.StatusBadRequest)
+			return
+		}
+		mappings.SetDocsHost(host)
+		pages.reset()
+		log.Printf("Documentation host changed to %s", host)
+		fmt.Fprintln(w, host)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
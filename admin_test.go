@@ -0,0 +1,186 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/noaleibo1/go-import-redirector/redirector"
+)
+
+// doAdminRequest returns the response of the admin API to a request
+// with the given method and body for path, carrying token if not empty.
+func doAdminRequest(method, path, body, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "http://localhost:8081"+path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	adminHandler().ServeHTTP(w, req)
+	return w
+}
+
+// loadedCouples returns a copy of the loaded couples,
+// without the files they were read from.
+func loadedCouples() map[string]redirector.Couple {
+	all := map[string]redirector.Couple{}
+	for importPath, c := range mappings.All() {
+		cc := *c
+		cc.Source = ""
+		all[importPath] = cc
+	}
+	return all
+}
+
+func TestAdminDocsite(t *testing.T) {
+	defer mappings.SetDocsHost(mappings.DocsHost())
+	mappings.SetDocsHost("godoc.org")
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	before := loadedCouples()
+	page := doRequest(redirect, "GET", "http://rsc.io/x86").Body.String()
+	if !strings.Contains(page, "https://godoc.org/rsc.io/x86") {
+		t.Fatalf("page before PUT /docsite does not link godoc.org:\n%s", page)
+	}
+
+	if w := doAdminRequest("PUT", "/docsite", "pkg.go.dev\n", ""); w.Code != 200 || w.Body.String() != "pkg.go.dev\n" {
+		t.Fatalf("PUT /docsite: %d %q", w.Code, w.Body.String())
+	}
+	page = doRequest(redirect, "GET", "http://rsc.io/x86").Body.String()
+	if !strings.Contains(page, "https://pkg.go.dev/rsc.io/x86") || strings.Contains(page, "godoc.org") {
+		t.Errorf("page after PUT /docsite does not link pkg.go.dev only:\n%s", page)
+	}
+	if w := doAdminRequest("GET", "/docsite", "", ""); w.Body.String() != "pkg.go.dev\n" {
+		t.Errorf("GET /docsite = %q, want pkg.go.dev", w.Body.String())
+	}
+	if after := loadedCouples(); !reflect.DeepEqual(before, after) {
+		t.Errorf("PUT /docsite changed the couples from %v to %v", before, after)
+	}
+
+	for _, host := range []string{"", "/docs", "ftp://example.com/docs", `pkg.go.dev"><script>`} {
+		if w := doAdminRequest("PUT", "/docsite", host, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("PUT /docsite with %q: status %d, want 400", host, w.Code)
+		}
+	}
+	if got := mappings.DocsHost(); got != "pkg.go.dev" {
+		t.Errorf("rejected PUT /docsite changed the docs host to %q", got)
+	}
+	if w := doAdminRequest("PUT", "/docsite", "https://example.com/docs", ""); w.Code != 200 {
+		t.Errorf("PUT /docsite with a URL: status %d, want 200", w.Code)
+	}
+}
+
+func TestAdminMappings(t *testing.T) {
+	useMappings(t, `
+rsc.io/* https://github.com/rsc/*
+example.com/x https://hg.example.com/x vcs=hg
+`)
+	w := doAdminRequest("GET", "/mappings", "", "")
+	if w.Code != 200 || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("GET /mappings: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	var list []adminMapping
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	want := []adminMapping{
+		{Import: "example.com/x", Repo: "https://hg.example.com/x", VCS: "hg"},
+		{Import: "rsc.io/*", Repo: "https://github.com/rsc/*", VCS: "git"},
+	}
+	if len(list) != len(want) {
+		t.Fatalf("GET /mappings listed %+v, want %+v", list, want)
+	}
+	for i := range want {
+		if list[i].Import != want[i].Import || list[i].Repo != want[i].Repo || list[i].VCS != want[i].VCS {
+			t.Errorf("mapping %d = %+v, want %+v", i, list[i], want[i])
+		}
+	}
+	if w := doAdminRequest("DELETE", "/mappings", "", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE /mappings: status %d, want 405", w.Code)
+	}
+}
+
+func TestAdminReload(t *testing.T) {
+	useMappings(t, "example.com/old https://github.com/ex/old\n")
+	defer func(old string) { filePath = old }(filePath)
+	filePath = writeTemp(t, "config.txt", "example.com/new https://github.com/ex/new\n")
+
+	if w := doAdminRequest("GET", "/reload", "", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /reload: status %d, want 405", w.Code)
+	}
+	w := doAdminRequest("POST", "/reload", "", "")
+	if w.Code != 200 || w.Body.String() != "reloaded 1 mappings\n" {
+		t.Fatalf("POST /reload: %d %q", w.Code, w.Body.String())
+	}
+	if mappings.Match("example.com/new") == nil || mappings.Match("example.com/old") != nil {
+		t.Error("POST /reload did not replace the couples")
+	}
+
+	filePath = ""
+	if w := doAdminRequest("POST", "/reload", "", ""); w.Code != http.StatusConflict {
+		t.Errorf("POST /reload without a config file: status %d, want 409", w.Code)
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	useMappings(t, "example.com/x https://github.com/ex/x\n")
+	setFlag(t, "admin-token", "s3cret")
+
+	for _, token := range []string{"", "wrong"} {
+		w := doAdminRequest("GET", "/mappings", "", token)
+		if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("GET /mappings with token %q: %d, want 401 with WWW-Authenticate", token, w.Code)
+		}
+	}
+	if w := doAdminRequest("PUT", "/docsite", "pkg.go.dev", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("PUT /docsite with the wrong token: status %d, want 401", w.Code)
+	}
+	if w := doAdminRequest("GET", "/mappings", "", "s3cret"); w.Code != 200 {
+		t.Errorf("GET /mappings with the token: status %d, want 200", w.Code)
+	}
+}
+
+func TestAdminServerTimeouts(t *testing.T) {
+	srv := newAdminServer("localhost:8081")
+	pub := newServer(nil)
+	if srv.ReadTimeout != pub.ReadTimeout || srv.WriteTimeout != pub.WriteTimeout ||
+		srv.IdleTimeout != pub.IdleTimeout || srv.MaxHeaderBytes != pub.MaxHeaderBytes {
+		t.Errorf("admin server limits %v %v %v %d, want those of newServer %v %v %v %d",
+			srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout, srv.MaxHeaderBytes,
+			pub.ReadTimeout, pub.WriteTimeout, pub.IdleTimeout, pub.MaxHeaderBytes)
+	}
+	if srv.ReadTimeout == 0 || srv.WriteTimeout == 0 {
+		t.Error("admin server has no read or write timeout")
+	}
+}
+
+func TestCheckAdminAddr(t *testing.T) {
+	tests := []struct {
+		addr  string
+		token string
+		ok    bool
+	}{
+		{"localhost:8081", "", true},
+		{"127.0.0.1:8081", "", true},
+		{"[::1]:8081", "", true},
+		{":8081", "", false},
+		{"0.0.0.0:8081", "", false},
+		{"192.0.2.1:8081", "", false},
+		{"admin.example.com:8081", "", false},
+		{":8081", "s3cret", true},
+		{"192.0.2.1:8081", "s3cret", true},
+		{"localhost", "", false},
+	}
+	for _, tt := range tests {
+		setFlag(t, "admin-token", tt.token)
+		if err := checkAdminAddr(tt.addr); (err == nil) != tt.ok {
+			t.Errorf("checkAdminAddr(%q) with token %q = %v, want ok=%v", tt.addr, tt.token, err, tt.ok)
+		}
+	}
+}
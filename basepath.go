@@ -0,0 +1,54 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// underBasePath returns a handler that, with -base-path, strips the base
+// path from each request before calling h, as when the redirector is served
+// below a path such as /vanity/ of a reverse proxy. Requests outside the base
+// path get 404 Not Found. Redirects to paths on the same server, as sent by
+// the mux, get the base path back.
+func underBasePath(h http.Handler) http.Handler {
+	if *basePath == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		p := req.URL.Path
+		if p != *basePath && !strings.HasPrefix(p, *basePath+"/") {
+			w.Header().Set("Cache-Control", "no-store")
+			http.NotFound(w, req)
+			return
+		}
+		r := new(http.Request)
+		*r = *req
+		r.URL = new(url.URL)
+		*r.URL = *req.URL
+		r.URL.Path = p[len(*basePath):]
+		r.URL.RawPath = ""
+		if r.URL.Path == "" {
+			r.URL.Path = "/"
+		}
+		h.ServeHTTP(basePathWriter{w}, r)
+	})
+}
+
+// A basePathWriter is an http.ResponseWriter that adds -base-path
+// to the Location header of redirects to paths on the same server.
+type basePathWriter struct {
+	http.ResponseWriter
+}
+
+func (w basePathWriter) WriteHeader(status int) {
+	h := w.Header()
+	if loc := h.Get("Location"); strings.HasPrefix(loc, "/") && !strings.HasPrefix(loc, "//") {
+		h.Set("Location", *basePath+loc)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,40 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestBasePath(t *testing.T) {
+	setFlag(t, "base-path", "/vanity")
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	h := newHandler()
+
+	w := doRequest(h, "GET", "http://rsc.io/vanity/x86/x86asm?go-get=1")
+	if got := goImportTag(w.Body.String(), "rsc.io/x86"); w.Code != 200 || got != "rsc.io/x86 git https://github.com/rsc/x86" {
+		t.Errorf("inside -base-path: %d with go-import tag %q", w.Code, got)
+	}
+	for _, url := range []string{
+		"http://rsc.io/x86?go-get=1",
+		"http://rsc.io/vanityx/x86?go-get=1",
+		"http://rsc.io/other/vanity/x86?go-get=1",
+	} {
+		if w := doRequest(h, "GET", url); w.Code != 404 {
+			t.Errorf("%s, outside -base-path: status %d, want 404", url, w.Code)
+		}
+	}
+
+	// Redirects to paths of the same server, from the mux cleaning
+	// the path or from -trailing-slash, keep the base path.
+	w = doRequest(h, "GET", "http://rsc.io/vanity//x86")
+	if loc := w.Header().Get("Location"); w.Code != 301 || loc != "/vanity/x86" {
+		t.Errorf("unclean path inside -base-path: %d to %q, want 301 to /vanity/x86", w.Code, loc)
+	}
+
+	setFlag(t, "trailing-slash", "add")
+	w = doRequest(newHandler(), "GET", "http://rsc.io/vanity/x86")
+	if loc := w.Header().Get("Location"); w.Code != 301 || loc != "/vanity/x86/" {
+		t.Errorf("-trailing-slash=add inside -base-path: %d to %q, want 301 to /vanity/x86/", w.Code, loc)
+	}
+}
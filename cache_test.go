@@ -0,0 +1,99 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/noaleibo1/go-import-redirector/redirector"
+)
+
+func TestCacheControlFromRef(t *testing.T) {
+	tests := []struct {
+		couple redirector.Couple
+		want   string
+	}{
+		{redirector.Couple{Repo: "https://github.com/rsc/x86", Ref: "v1.2.3"}, "public, max-age=86400"},
+		{redirector.Couple{Repo: "https://github.com/rsc/x86", Ref: "refs/tags/release"}, "public, max-age=86400"},
+		{redirector.Couple{Repo: "https://github.com/rsc/x86", Ref: "0123abcd"}, "public, max-age=86400"},
+		{redirector.Couple{Repo: "https://github.com/rsc/x86", Ref: "main"}, "public, max-age=300"},
+		{redirector.Couple{Repo: "https://github.com/rsc/x86", Ref: "refs/heads/v2"}, "public, max-age=300"},
+		{redirector.Couple{Repo: "https://github.com/rsc/x86?ref=develop"}, "public, max-age=300"},
+		{redirector.Couple{Repo: "https://github.com/rsc/x86"}, "public, max-age=3600"},
+		{redirector.Couple{Repo: "https://github.com/rsc/x86", Ref: "main", CacheControl: "no-cache"}, "no-cache"},
+	}
+	for _, tt := range tests {
+		if got := cacheControl(&tt.couple); got != tt.want {
+			t.Errorf("cacheControl(%+v) = %q, want %q", tt.couple, got, tt.want)
+		}
+	}
+
+	setFlag(t, "cache-max-age", "0")
+	if got := cacheControl(&redirector.Couple{Repo: "https://github.com/rsc/x86"}); got != "" {
+		t.Errorf("with -cache-max-age=0, cacheControl = %q, want none", got)
+	}
+}
+
+func TestCacheControlTagVersusBranch(t *testing.T) {
+	useMappings(t, `
+example.com/tag https://github.com/ex/tag#v1.0.0
+example.com/branch https://github.com/ex/branch#main
+`)
+	tag := doRequest(redirect, "GET", "http://example.com/tag?go-get=1").Header().Get("Cache-Control")
+	branch := doRequest(redirect, "GET", "http://example.com/branch?go-get=1").Header().Get("Cache-Control")
+	if tag != "public, max-age=86400" || branch != "public, max-age=300" {
+		t.Errorf("Cache-Control for a tag ref %q and a branch ref %q, want a day and five minutes", tag, branch)
+	}
+}
+
+func TestCacheControlHeader(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	setFlag(t, "cache-max-age", "600")
+
+	if got := doRequest(redirect, "GET", "http://rsc.io/x86?go-get=1").Header().Get("Cache-Control"); got != "public, max-age=600" {
+		t.Errorf("Cache-Control on a match = %q, want public, max-age=600", got)
+	}
+	w := doRequest(redirect, "GET", "http://rsc.io/nope?go-get=1")
+	if w.Code != 404 || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("404 response: %d with Cache-Control %q, want 404 with no-store", w.Code, w.Header().Get("Cache-Control"))
+	}
+}
+
+func TestETag(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	const url = "http://rsc.io/x86?go-get=1"
+	w := doRequest(redirect, "GET", url)
+	etag := w.Header().Get("ETag")
+	if w.Code != 200 || etag == "" {
+		t.Fatalf("%s: status %d with ETag %q, want 200 with an ETag", url, w.Code, etag)
+	}
+
+	tests := []struct {
+		ifNoneMatch string
+		status      int
+	}{
+		{etag, 304},
+		{"W/" + etag, 304},
+		{`"other", ` + etag, 304},
+		{"*", 304},
+		{`"other"`, 200},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("If-None-Match", tt.ifNoneMatch)
+		w := httptest.NewRecorder()
+		redirect(w, req)
+		if w.Code != tt.status {
+			t.Errorf("If-None-Match: %s: status %d, want %d", tt.ifNoneMatch, w.Code, tt.status)
+		}
+		if tt.status == 304 && w.Body.Len() != 0 {
+			t.Errorf("If-None-Match: %s: 304 with body %q", tt.ifNoneMatch, w.Body.String())
+		}
+		if got := w.Header().Get("ETag"); got != etag {
+			t.Errorf("If-None-Match: %s: ETag %q, want %q", tt.ifNoneMatch, got, etag)
+		}
+	}
+}
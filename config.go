@@ -0,0 +1,569 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/noaleibo1/go-import-redirector/redirector"
+)
+
+// loadingFile is the config file being read.
+var loadingFile string
+
+// configFetchTimeout bounds the time taken to fetch a config from a URL.
+const configFetchTimeout = 30 * time.Second
+
+// readFile loads import couples into m from filePath, which names either
+// a config file, a directory of them, or an http or https URL serving one.
+func readFile(m *redirector.Mappings) error {
+	if strings.HasPrefix(filePath, "http://") || strings.HasPrefix(filePath, "https://") {
+		return readURL(m, filePath)
+	}
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return readDir(m, filePath)
+	}
+	return readConfigFile(m, filePath)
+}
+
+// readDir loads import couples into m from the files in dir named *.txt, *.json,
+// or *.toml, in lexical order.
+func readDir(m *redirector.Mappings, dir string) error {
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, name := range names { // sorted by Glob
+		if ext := filepath.Ext(name); ext != ".txt" && ext != ".json" && ext != ".toml" {
+			continue
+		}
+		if fi, err := os.Stat(name); err != nil || fi.IsDir() {
+			continue
+		}
+		if err := readConfigFile(m, name); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		n++
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: no .txt, .json, or .toml config files", dir)
+	}
+	return nil
+}
+
+// readConfigFile loads import couples into m from the named file. A file named *.json,
+// or whose first non-space character is {, is read as a JSON config,
+// and a file named *.toml as a TOML config;
+// any other file is read as lines of "<import> <repo> [options]".
+func readConfigFile(m *redirector.Mappings, name string) error {
+	log.Printf("Reading file: %s", name)
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	loadingFile = name
+	r := bufio.NewReader(f)
+	switch {
+	case strings.HasSuffix(name, ".json") || startsWithBrace(r):
+		return readJSON(m, r)
+	case strings.HasSuffix(name, ".toml"):
+		return readTOML(m, r)
+	}
+	return readText(m, r)
+}
+
+// readURL loads import couples into m from the config served at rawurl,
+// read as JSON if its name ends in .json, it is served as application/json,
+// or its first non-space character is {, and as TOML if its name ends
+// in .toml or it is served as application/toml.
+func readURL(m *redirector.Mappings, rawurl string) error {
+	log.Printf("Fetching config: %s", rawurl)
+	client := &http.Client{Timeout: configFetchTimeout}
+	resp, err := client.Get(rawurl)
+	if err != nil {
+		return fmt.Errorf("fetching config: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching config %s: %s", rawurl, resp.Status)
+	}
+	loadingFile = rawurl
+	r := bufio.NewReader(resp.Body)
+	u := resp.Request.URL
+	ctype := resp.Header.Get("Content-Type")
+	switch {
+	case strings.HasSuffix(u.Path, ".json") || strings.HasPrefix(ctype, "application/json") || startsWithBrace(r):
+		err = readJSON(m, r)
+	case strings.HasSuffix(u.Path, ".toml") || strings.HasPrefix(ctype, "application/toml"):
+		err = readTOML(m, r)
+	default:
+		err = readText(m, r)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", rawurl, err)
+	}
+	return nil
+}
+
+// addCouple adds c, read from loadingFile, to m for importPath.
+// A couple already read for the same import path is handled
+// as -on-duplicate says: reported as an error, replaced after
+// logging a warning, or replaced silently. A new import path is
+// checked against -max-entries and, when truncating, dropped.
+func addCouple(m *redirector.Mappings, importPath string, c *redirector.Couple) error {
+	c.Source = loadingFile
+	if old := m.Get(importPath); old == nil {
+		if ok, err := checkEntryLimit(m); err != nil {
+			return err
+		} else if !ok {
+			return nil
+		}
+	} else {
+		where := "earlier in the same file"
+		if old.Source != c.Source {
+			where = "also in " + old.Source
+		}
+		switch *onDuplicate {
+		case "error":
+			return fmt.Errorf("duplicate import path %s, configured %s", strings.TrimSuffix(importPath, "/"), where)
+		case "warn":
+			log.Printf("warning: duplicate import path %s, configured %s; using the last", strings.TrimSuffix(importPath, "/"), where)
+		}
+	}
+	m.Add(importPath, c)
+	return nil
+}
+
+// startsWithBrace reports whether the first non-space byte in r is {,
+// without consuming any input.
+func startsWithBrace(r *bufio.Reader) bool {
+	for n := 1; ; n++ {
+		b, _ := r.Peek(n)
+		if len(b) < n {
+			return false
+		}
+		switch b[n-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{':
+			return true
+		}
+		return false
+	}
+}
+
+// readText loads import couples into m from the line-based config format.
+func readText(m *redirector.Mappings, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, *maxLineBytes)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		switch {
+		case len(fields) == 0:
+			continue
+		case strings.HasPrefix(fields[0], "@"):
+			if err := parseDirective(m, fields); err != nil {
+				return fmt.Errorf("file malformed: %s: %v", scanner.Text(), err)
+			}
+		case len(fields) == 1:
+			return fmt.Errorf("file malformed: %s", scanner.Text())
+		default:
+			importPath, c, err := newCouple(fields[0], fields[1])
+			if err != nil {
+				return fmt.Errorf("file malformed: %s: %v", scanner.Text(), err)
+			}
+			if err := parseOptions(c, fields[2:]); err != nil {
+				return fmt.Errorf("file malformed: %s: %v", scanner.Text(), err)
+			}
+			if err := addCouple(m, importPath, c); err != nil {
+				return fmt.Errorf("file malformed: %s: %v", scanner.Text(), err)
+			}
+		}
+	}
+	if err := scanner.Err(); err == bufio.ErrTooLong {
+		return fmt.Errorf("file malformed: line longer than %d bytes (see -max-line-bytes)", *maxLineBytes)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// newCouple returns the normalized import path and a new couple
+// for the given import and repo fields from a config file,
+// after expanding references to environment variables
+// and repo shorthands.
+func newCouple(importField, repoField string) (string, *redirector.Couple, error) {
+	importPath := os.ExpandEnv(importField)
+	repoPath := expandRepo(os.ExpandEnv(repoField))
+	if repoPath != repoField && !strings.Contains(repoPath, "://") {
+		return "", nil, fmt.Errorf("repo expands to %q, which is not a full URL (unset environment variable?)", repoPath)
+	}
+	importPath, c := makeCouple(importPath, repoPath)
+	return importPath, c, nil
+}
+
+// makeCouple returns the normalized import path and a new couple for
+// importPath and repoPath. The repo URL is kept exactly as given,
+// except that a fragment is taken as the couple's ref.
+func makeCouple(importPath, repoPath string) (string, *redirector.Couple) {
+	c := &redirector.Couple{Repo: repoPath}
+	if i := strings.Index(repoPath, "#"); i >= 0 {
+		c.Repo, c.Ref = repoPath[:i], repoPath[i+1:]
+	}
+	return strings.TrimSuffix(importPath, "/") + "/", c
+}
+
+// repoShorthands maps the prefixes accepted by expandRepo to their URLs.
+var repoShorthands = map[string]string{
+	"gh:": "https://github.com/",
+	"gl:": "https://gitlab.com/",
+	"bb:": "https://bitbucket.org/",
+}
+
+// expandRepo expands a repo shorthand, such as gh:rsc/x86 for
+// https://github.com/rsc/x86. Anything else is returned unchanged.
+func expandRepo(repo string) string {
+	for prefix, url := range repoShorthands {
+		if strings.HasPrefix(repo, prefix) {
+			return url + repo[len(prefix):]
+		}
+	}
+	return repo
+}
+
+// jsonConfig is the structure of a JSON config file, and through its
+// toml tags of a TOML config file (see readTOML). A JSON example:
+//
+//	{
+//		"defaults": {"vcs": "git", "hostVCS": {"example.com": "hg"}},
+//		"mappings": [
+//			{"import": "rsc.io/*", "repo": "https://github.com/rsc/*"},
+//			{"import": "example.com/x", "repo": "https://hg.example.com/x", "goSource": "_ _ _"}
+//		]
+//	}
+type jsonConfig struct {
+	Defaults struct {
+		VCS             string            `json:"vcs" toml:"vcs"`
+		HostVCS         map[string]string `json:"hostVCS" toml:"hostVCS"`
+		DefaultRedirect string            `json:"defaultRedirect" toml:"defaultRedirect"`
+	} `json:"defaults" toml:"defaults"`
+	Mappings []jsonMapping `json:"mappings" toml:"mapping"`
+}
+
+// A jsonMapping is a single couple in a JSON or TOML config file.
+// Its fields correspond to the options of the line-based format.
+// Fields not listed here are reported as errors.
+type jsonMapping struct {
+	Import         string `json:"import" toml:"import"`
+	Repo           string `json:"repo" toml:"repo"`
+	VCS            string `json:"vcs" toml:"vcs"`
+	GoSource       string `json:"goSource" toml:"goSource"`
+	CacheControl   string `json:"cacheControl" toml:"cacheControl"`
+	Subdir         string `json:"subdir" toml:"subdir"`
+	Ref            string `json:"ref" toml:"ref"`
+	DocsHost       string `json:"docsHost" toml:"docsHost"`
+	NoDocs         bool   `json:"noDocs" toml:"noDocs"`
+	RedirectStatus int    `json:"redirectStatus" toml:"redirectStatus"`
+	Depth          int    `json:"depth" toml:"depth"`
+	Enabled        *bool  `json:"enabled" toml:"enabled"`
+	Maintenance    bool   `json:"maintenance" toml:"maintenance"`
+
+	Hosts map[string]string `json:"hosts" toml:"hosts"`
+}
+
+// readJSON loads import couples into m from a JSON config.
+func readJSON(m *redirector.Mappings, r io.Reader) error {
+	var cfg jsonConfig
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return fmt.Errorf("file malformed: %v", err)
+	}
+	return loadConfig(m, &cfg, func(i int) string {
+		return fmt.Sprintf("mappings[%d]", i)
+	})
+}
+
+// loadConfig loads the import couples of cfg, read from a JSON or TOML
+// config, into m, naming mapping i as where(i) in errors.
+// The defaults apply only where the corresponding flags were not given.
+func loadConfig(m *redirector.Mappings, cfg *jsonConfig, where func(i int) string) error {
+	d := cfg.Defaults
+	if d.VCS != "" {
+		if !validVCS(d.VCS) {
+			return fmt.Errorf("file malformed: defaults: unknown vcs %q", d.VCS)
+		}
+		if !flagsGiven["vcs"] {
+			m.SetDefaultVCS(d.VCS)
+		}
+	}
+	for host, v := range d.HostVCS {
+		if !validVCS(v) {
+			return fmt.Errorf("file malformed: defaults: hostVCS: unknown vcs %q for %s", v, host)
+		}
+		m.SetHostVCS(host, v)
+	}
+	if d.DefaultRedirect != "" && !flagsGiven["default-redirect"] {
+		m.SetDefaultRedirect(d.DefaultRedirect)
+	}
+
+	for i, jm := range cfg.Mappings {
+		if err := addJSONMapping(m, jm); err != nil {
+			return fmt.Errorf("file malformed: %s (import %q): %v", where(i), jm.Import, err)
+		}
+	}
+	return nil
+}
+
+// addJSONMapping adds the couple described by jm to m.
+func addJSONMapping(m *redirector.Mappings, jm jsonMapping) error {
+	switch {
+	case jm.Import == "":
+		return fmt.Errorf("missing import")
+	case jm.Repo == "":
+		return fmt.Errorf("missing repo")
+	case jm.VCS != "" && !validVCS(jm.VCS):
+		return fmt.Errorf("unknown vcs %q", jm.VCS)
+	case jm.Subdir != "" && !validSubdir(jm.Subdir):
+		return fmt.Errorf("invalid subdir %q", jm.Subdir)
+	case jm.DocsHost != "" && !validDocsHost(jm.DocsHost):
+		return fmt.Errorf("invalid docsHost %q", jm.DocsHost)
+	case jm.Depth < 0:
+		return fmt.Errorf("invalid depth %d", jm.Depth)
+	case jm.RedirectStatus != 0 && !validRedirectStatus(jm.RedirectStatus):
+		return fmt.Errorf("invalid redirectStatus %d: must be 301 or 302", jm.RedirectStatus)
+	}
+	importPath, c, err := newCouple(jm.Import, jm.Repo)
+	if err != nil {
+		return err
+	}
+	c.VCS = jm.VCS
+	c.GoSource = jm.GoSource
+	c.CacheControl = jm.CacheControl
+	c.Subdir = jm.Subdir
+	c.DocsHost = jm.DocsHost
+	c.NoDocs = jm.NoDocs
+	c.RedirectStatus = jm.RedirectStatus
+	c.Depth = jm.Depth
+	if jm.Ref != "" {
+		c.Ref = jm.Ref
+	}
+	c.Disabled = jm.Enabled != nil && !*jm.Enabled
+	c.Maintenance = jm.Maintenance
+	for elem, host := range jm.Hosts {
+		if elem == "" || !validRepoHost(host) {
+			return fmt.Errorf("invalid hosts entry %q: %q", elem, host)
+		}
+	}
+	if len(jm.Hosts) > 0 {
+		c.Hosts = jm.Hosts
+	}
+	return addCouple(m, importPath, c)
+}
+
+// flagsGiven records the names of the flags given on the command line
+// or through the environment, which the defaults of a config do not override.
+// It is set once at startup, so that reloads see the same flags.
+var flagsGiven map[string]bool
+
+// recordFlagsGiven sets flagsGiven from the flags set so far.
+func recordFlagsGiven() {
+	flagsGiven = map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		flagsGiven[f.Name] = true
+	})
+}
+
+// newMappings returns empty mappings to load a config into,
+// with the defaults given by the -vcs and -default-redirect flags.
+func newMappings() *redirector.Mappings {
+	m := redirector.NewMappings()
+	m.SetDefaultVCS(*vcs)
+	m.SetDefaultRedirect(*defaultRedirect)
+	return m
+}
+
+// parseDirective applies to m a config file line starting with @,
+// which sets defaults rather than adding a couple.
+func parseDirective(m *redirector.Mappings, fields []string) error {
+	switch fields[0] {
+	case "@default-vcs":
+		if len(fields) != 3 {
+			return fmt.Errorf("want @default-vcs <host> <vcs>")
+		}
+		if !validVCS(fields[2]) {
+			return fmt.Errorf("unknown vcs %q", fields[2])
+		}
+		m.SetHostVCS(fields[1], fields[2])
+	default:
+		return fmt.Errorf("unknown directive %s", fields[0])
+	}
+	return nil
+}
+
+// validVCS reports whether vcs names a version control system known to the go command.
+func validVCS(vcs string) bool {
+	switch vcs {
+	case "git", "hg", "svn", "bzr", "fossil":
+		return true
+	}
+	return false
+}
+
+// validSubdir reports whether dir is a usable subdirectory of a repo.
+func validSubdir(dir string) bool {
+	return dir != "" && !strings.HasPrefix(dir, "/") && !strings.HasSuffix(dir, "/") && !strings.Contains(dir, "..")
+}
+
+// parseOptions sets the fields of c from the optional key=value
+// fields following the import and repo on a config file line.
+// Environment variables in each value are expanded before it is checked.
+func parseOptions(c *redirector.Couple, opts []string) error {
+	for _, opt := range opts {
+		i := strings.Index(opt, "=")
+		if i < 0 {
+			return fmt.Errorf("option %q is not of the form key=value", opt)
+		}
+		key, value := opt[:i], os.ExpandEnv(opt[i+1:])
+		switch key {
+		case "cache-control":
+			c.CacheControl = value
+		case "ref":
+			c.Ref = value
+		case "subdir":
+			if !validSubdir(value) {
+				return fmt.Errorf("invalid subdir %q", value)
+			}
+			c.Subdir = value
+		case "vcs":
+			if !validVCS(value) {
+				return fmt.Errorf("unknown vcs %q", value)
+			}
+			c.VCS = value
+		case "docs-host":
+			if !validDocsHost(value) {
+				return fmt.Errorf("invalid docs-host %q", value)
+			}
+			c.DocsHost = value
+		case "no-docs":
+			noDocs, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid no-docs value %q", value)
+			}
+			c.NoDocs = noDocs
+		case "redirect-status":
+			n, err := strconv.Atoi(value)
+			if err != nil || !validRedirectStatus(n) {
+				return fmt.Errorf("invalid redirect-status %q: must be 301 or 302", value)
+			}
+			c.RedirectStatus = n
+		case "depth":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid depth %q", value)
+			}
+			c.Depth = n
+		case "enabled":
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid enabled value %q", value)
+			}
+			c.Disabled = !enabled
+		case "maintenance":
+			maintenance, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid maintenance value %q", value)
+			}
+			c.Maintenance = maintenance
+		case "hosts":
+			hosts, err := parseHosts(value)
+			if err != nil {
+				return err
+			}
+			c.Hosts = hosts
+		default:
+			return fmt.Errorf("unknown option %q", key)
+		}
+	}
+	return nil
+}
+
+// parseHosts parses the value of a hosts= option, a comma-separated list
+// of elem=host pairs, such as aws=github.com/aws-mirror,*=github.com/mirror.
+func parseHosts(value string) (map[string]string, error) {
+	hosts := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		i := strings.Index(pair, "=")
+		if i <= 0 || !validRepoHost(pair[i+1:]) {
+			return nil, fmt.Errorf("invalid hosts entry %q: must be elem=host", pair)
+		}
+		hosts[pair[:i]] = pair[i+1:]
+	}
+	return hosts, nil
+}
+
+// validRedirectStatus reports whether status may be sent for redirects.
+func validRedirectStatus(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusFound
+}
+
+// validDocsHost reports whether v can name a documentation site:
+// a host, optionally followed by a path, or an http or https URL.
+func validDocsHost(v string) bool {
+	if i := strings.Index(v, "://"); i >= 0 {
+		if scheme := v[:i]; scheme != "http" && scheme != "https" {
+			return false
+		}
+		v = v[i+len("://"):]
+	}
+	return v != "" && !strings.HasPrefix(v, "/") && !strings.ContainsAny(v, " \t\"'<>")
+}
+
+// validRepoHost reports whether v can replace the {host} of a repo URL:
+// a host, optionally followed by a path, without a scheme.
+func validRepoHost(v string) bool {
+	return v != "" && !strings.HasPrefix(v, "/") && !strings.HasSuffix(v, "/") &&
+		!strings.Contains(v, "://") && !strings.ContainsAny(v, " \t\"'<>")
+}
+
+// truncatedLoad is the mappings for which checkEntryLimit last warned,
+// so that it warns once per load rather than once per dropped couple.
+var truncatedLoad *redirector.Mappings
+
+// checkEntryLimit reports whether another import couple may be loaded
+// into m without exceeding -max-entries. Once the limit is reached it returns
+// an error, or, with -truncate-entries, false after logging a warning the
+// first time for m.
+func checkEntryLimit(m *redirector.Mappings) (bool, error) {
+	if *maxEntries <= 0 || m.Len() < *maxEntries {
+		return true, nil
+	}
+	if !*truncateEntries {
+		return false, fmt.Errorf("too many import couples: limit is %d (see -max-entries)", *maxEntries)
+	}
+	if truncatedLoad != m {
+		truncatedLoad = m
+		log.Printf("warning: more than %d import couples configured, ignoring the rest (see -max-entries)", *maxEntries)
+	}
+	return false, nil
+}
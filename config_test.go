@@ -0,0 +1,564 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/noaleibo1/go-import-redirector/redirector"
+)
+
+// writeTemp writes content to a file with the given name in a new
+// temporary directory, removed when the test ends, and returns its path.
+func writeTemp(t *testing.T, name, content string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readConfig loads the couples of the config file at path,
+// in place of any loaded before.
+func readConfig(path string) error {
+	filePath = path
+	mappings = newMappings()
+	mappings.SetDocsHost(*docsSite)
+	mappings.DocsTarget = *docsTarget
+	mappings.WildcardDots, mappings.DotReplacement = *wildcardDots, *dotReplacement
+	return readFile(mappings)
+}
+
+func TestMaxEntries(t *testing.T) {
+	defer func(old string) { filePath = old }(filePath)
+	config := writeTemp(t, "config.txt", `
+example.com/a https://github.com/ex/a
+example.com/b https://github.com/ex/b
+example.com/c https://github.com/ex/c
+`)
+	setFlag(t, "max-entries", "2")
+
+	err := readConfig(config)
+	if err == nil || !strings.Contains(err.Error(), "too many import couples: limit is 2") {
+		t.Errorf("readFile over -max-entries = %v, want too many import couples", err)
+	}
+
+	setFlag(t, "truncate-entries", "true")
+	if err := readConfig(config); err != nil {
+		t.Fatalf("readFile with -truncate-entries: %v", err)
+	}
+	if _, ok := mappings.All()["example.com/c/"]; mappings.Len() != 2 || ok {
+		t.Errorf("with -truncate-entries, loaded %v, want the first two couples", mappings.All())
+	}
+
+	setFlag(t, "max-entries", "3")
+	setFlag(t, "truncate-entries", "false")
+	if err := readConfig(config); err != nil {
+		t.Errorf("readFile at -max-entries: %v", err)
+	}
+}
+
+func TestMaxEntriesDuplicates(t *testing.T) {
+	defer func(old string) { filePath = old }(filePath)
+	defer log.SetOutput(ioutil.Discard)
+	setFlag(t, "max-entries", "2")
+	setFlag(t, "on-duplicate", "last-wins")
+
+	// A duplicate at the limit replaces its couple rather than counting as another.
+	err := readConfig(writeTemp(t, "config.txt", `
+example.com/a https://github.com/ex/a
+example.com/b https://github.com/ex/b
+example.com/a https://github.com/ex/a2
+`))
+	if err != nil {
+		t.Fatalf("readFile with a duplicate at -max-entries: %v", err)
+	}
+	if c := mappings.Get("example.com/a/"); c == nil || c.Repo != "https://github.com/ex/a2" {
+		t.Errorf("example.com/a/ read as %+v, want the last repo", c)
+	}
+
+	// Truncating warns once per load, however many couples and files are dropped.
+	dir, err := ioutil.TempDir("", "entries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, config := range map[string]string{
+		"1.txt": "example.com/a https://github.com/ex/a\nexample.com/b https://github.com/ex/b\nexample.com/c https://github.com/ex/c\n",
+		"2.txt": "example.com/d https://github.com/ex/d\nexample.com/b https://github.com/ex/b2\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(config), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setFlag(t, "truncate-entries", "true")
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	if err := readConfig(dir); err != nil {
+		t.Fatalf("readFile with -truncate-entries: %v", err)
+	}
+	if n := strings.Count(buf.String(), "ignoring the rest"); n != 1 {
+		t.Errorf("logged %d truncation warnings, want 1:\n%s", n, buf.String())
+	}
+	if c := mappings.Get("example.com/b/"); mappings.Len() != 2 || c == nil || c.Repo != "https://github.com/ex/b2" {
+		t.Errorf("with -truncate-entries, loaded %v, want a and the last b", mappings.All())
+	}
+}
+
+func TestConfigEnvExpansion(t *testing.T) {
+	t.Setenv("REPO_BASE", "https://github.com/rsc")
+	t.Setenv("IMPORT_HOST", "rsc.io")
+	useMappings(t, `
+${IMPORT_HOST}/x86 ${REPO_BASE}/x86
+rsc.io/pdf $REPO_BASE/pdf
+`)
+	for importPath, want := range map[string]string{
+		"rsc.io/x86/": "https://github.com/rsc/x86",
+		"rsc.io/pdf/": "https://github.com/rsc/pdf",
+	} {
+		if c := mappings.All()[importPath]; c == nil || c.Repo != want {
+			t.Errorf("%s read as %+v, want repo %s", importPath, c, want)
+		}
+	}
+
+	err := readConfig(writeTemp(t, "config.txt", "rsc.io/x86 ${GIR_TEST_UNSET}/x86\n"))
+	if err == nil || !strings.Contains(err.Error(), `repo expands to "/x86", which is not a full URL (unset environment variable?)`) {
+		t.Errorf("readFile with an unset variable = %v, want an error naming the expanded repo", err)
+	}
+}
+
+func TestConfigOptionEnvExpansion(t *testing.T) {
+	t.Setenv("X86_REF", "v1.2.0")
+	t.Setenv("X86_VCS", "hg")
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86 ref=${X86_REF} vcs=$X86_VCS\n")
+	if c := mappings.All()["rsc.io/x86/"]; c == nil || c.Ref != "v1.2.0" || c.VCS != "hg" {
+		t.Errorf("rsc.io/x86/ read as %+v, want ref v1.2.0 and vcs hg", c)
+	}
+
+	err := readConfig(writeTemp(t, "config.txt", "rsc.io/x86 https://github.com/rsc/x86 vcs=${GIR_TEST_UNSET}\n"))
+	if err == nil || !strings.Contains(err.Error(), `unknown vcs ""`) {
+		t.Errorf("readFile with an unset variable in an option = %v, want an error naming the expanded vcs", err)
+	}
+}
+
+func TestVCSPrecedence(t *testing.T) {
+	setFlag(t, "vcs", "bzr")
+	useMappings(t, `
+@default-vcs hg.example.com hg
+hg.example.com/own https://code.example.com/own vcs=svn
+hg.example.com/host https://code.example.com/host
+example.com/flag https://code.example.com/flag
+`)
+	tests := []struct {
+		importPath string
+		vcs        string
+	}{
+		{"hg.example.com/own", "svn"}, // per-entry vcs= beats @default-vcs
+		{"hg.example.com/host", "hg"}, // @default-vcs beats -vcs
+		{"example.com/flag", "bzr"},   // -vcs when nothing else says
+	}
+	for _, tt := range tests {
+		body := doRequest(redirect, "GET", "http://"+tt.importPath+"?go-get=1").Body.String()
+		if got := strings.Fields(goImportTag(body, tt.importPath)); len(got) < 2 || got[1] != tt.vcs {
+			t.Errorf("%s: go-import tag %q, want vcs %s", tt.importPath, got, tt.vcs)
+		}
+	}
+}
+
+func TestTextAndJSONConfigsAgree(t *testing.T) {
+	setFlag(t, "vcs", "git")
+	const text = `
+@default-vcs hg.example.com hg
+rsc.io/* https://github.com/rsc/*
+example.com/x https://github.com/example/x cache-control=no-cache subdir=go vcs=bzr
+example.com/off https://github.com/example/off enabled=false
+hg.example.com/y https://code.example.com/y
+`
+	const js = `{
+	"defaults": {"hostVCS": {"hg.example.com": "hg"}},
+	"mappings": [
+		{"import": "rsc.io/*", "repo": "https://github.com/rsc/*"},
+		{"import": "example.com/x", "repo": "https://github.com/example/x",
+		 "cacheControl": "no-cache", "subdir": "go", "vcs": "bzr"},
+		{"import": "example.com/off", "repo": "https://github.com/example/off", "enabled": false},
+		{"import": "hg.example.com/y", "repo": "https://code.example.com/y"}
+	]
+}`
+	useMappings(t, text)
+	fromText, textVCS := loadedCouples(), mappings.VCSFor("hg.example.com/y/", &redirector.Couple{})
+	useMappings(t, js)
+	fromJSON, jsonVCS := loadedCouples(), mappings.VCSFor("hg.example.com/y/", &redirector.Couple{})
+	if !reflect.DeepEqual(fromText, fromJSON) {
+		t.Errorf("text config couples:\n%+v\nJSON config couples:\n%+v", fromText, fromJSON)
+	}
+	if textVCS != jsonVCS {
+		t.Errorf("text config host VCS %v, JSON config host VCS %v", textVCS, jsonVCS)
+	}
+	body := doRequest(redirect, "GET", "http://hg.example.com/y?go-get=1").Body.String()
+	if got := goImportTag(body, "hg.example.com/y"); got != "hg.example.com/y hg https://code.example.com/y" {
+		t.Errorf("hostVCS default: go-import tag %q, want vcs hg", got)
+	}
+}
+
+func TestJSONConfigDefaults(t *testing.T) {
+	setFlag(t, "vcs", "git")
+	setFlag(t, "default-redirect", "")
+	useMappings(t, `{"defaults": {"vcs": "hg", "defaultRedirect": "https://example.com/home"},
+		"mappings": [{"import": "example.com/x", "repo": "https://code.example.com/x"}]}`)
+	if got := mappings.Match("example.com/x").VCS; got != "hg" {
+		t.Errorf("after loading: vcs = %s, want hg from the config defaults", got)
+	}
+	if got := mappings.DefaultRedirect(); got != "https://example.com/home" {
+		t.Errorf("after loading: default redirect = %s, want https://example.com/home", got)
+	}
+	if *vcs != "git" || *defaultRedirect != "" {
+		t.Errorf("config defaults changed the flags to -vcs %s, -default-redirect %s", *vcs, *defaultRedirect)
+	}
+
+	err := readConfig(writeTemp(t, "config.json", `{"mappings": [{"import": "example.com/x"}]}`))
+	if err == nil || !strings.Contains(err.Error(), "missing repo") {
+		t.Errorf("readFile with a mapping without repo = %v, want missing repo", err)
+	}
+}
+
+func TestExpandRepo(t *testing.T) {
+	tests := []struct {
+		repo string
+		want string
+	}{
+		{"gh:rsc/x86", "https://github.com/rsc/x86"},
+		{"gl:group/sub/project", "https://gitlab.com/group/sub/project"},
+		{"bb:team/repo", "https://bitbucket.org/team/repo"},
+		{"https://github.com/rsc/x86", "https://github.com/rsc/x86"},
+		{"https://example.com/gh:x", "https://example.com/gh:x"},
+	}
+	for _, tt := range tests {
+		if got := expandRepo(tt.repo); got != tt.want {
+			t.Errorf("expandRepo(%q) = %q, want %q", tt.repo, got, tt.want)
+		}
+	}
+
+	useMappings(t, "rsc.io/* gh:rsc/*\n")
+	w := doRequest(newHandler(), "GET", "https://rsc.io/x86?go-get=1")
+	if tag := goImportTag(w.Body.String(), "rsc.io/x86"); !strings.Contains(tag, "https://github.com/rsc/x86") {
+		t.Errorf("go-import tag for rsc.io/x86 with a gh: repo = %q", tag)
+	}
+}
+
+func TestRepoFragment(t *testing.T) {
+	useMappings(t, "example.com/ref https://github.com/ex/ref#release-1.2\n")
+	c := mappings.All()["example.com/ref/"]
+	if c == nil || c.Repo != "https://github.com/ex/ref" || c.Ref != "release-1.2" {
+		t.Errorf("couple for https://github.com/ex/ref#release-1.2 = %+v, want repo https://github.com/ex/ref and ref release-1.2", c)
+	}
+	if d, _, _ := resolve("example.com/ref/sub/"); d == nil || d.VCSRoot != "https://github.com/ex/ref#release-1.2" {
+		t.Errorf("resolve(example.com/ref/sub/) = %+v, want repo https://github.com/ex/ref#release-1.2", d)
+	}
+}
+
+func TestRepoTrailingSlashKept(t *testing.T) {
+	useMappings(t, `
+rsc.io/* https://github.com/rsc/*
+example.com/* https://git.example.com/*/
+example.com/exact/x https://git.example.com/exact
+`)
+	for path, want := range map[string]string{
+		"rsc.io/x86/x86asm/":   "https://github.com/rsc/x86",
+		"example.com/pdf/":     "https://git.example.com/pdf/",
+		"example.com/exact/x/": "https://git.example.com/exact",
+	} {
+		if d, _, _ := resolve(path); d == nil || d.VCSRoot != want {
+			t.Errorf("resolve(%s) = %+v, want repo %s", path, d, want)
+		}
+	}
+}
+
+func TestConfigDir(t *testing.T) {
+	defer func(old string) { loadingFile = old }(loadingFile)
+	dir := filepath.Dir(writeTemp(t, "a.txt", "rsc.io/* https://github.com/rsc/*\n"))
+	write := func(name, content string) string {
+		name = filepath.Join(dir, name)
+		if err := ioutil.WriteFile(name, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+	b := write("b.json", `{"mappings": [{"import": "example.com/x", "repo": "https://github.com/example/x"}]}`)
+	write("notes.md", "not a config\n")
+
+	if err := readConfig(dir); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"rsc.io/x86/":    "https://github.com/rsc/x86",
+		"example.com/x/": "https://github.com/example/x",
+	} {
+		if d, _, _ := resolve(path); d == nil || d.VCSRoot != want {
+			t.Errorf("resolve(%s) = %+v, want repo %s", path, d, want)
+		}
+	}
+
+	// The same root in two files is an error naming both.
+	write("c.txt", "example.com/x https://github.com/other/x\n")
+	err := readConfig(dir)
+	if err == nil || !strings.Contains(err.Error(), "duplicate import path example.com/x, configured also in "+b) {
+		t.Errorf("reading example.com/x from two files = %v, want a duplicate error naming %s", err, b)
+	}
+}
+
+func TestOnDuplicate(t *testing.T) {
+	defer log.SetOutput(ioutil.Discard)
+	defer func(path string, m *redirector.Mappings) { filePath, mappings = path, m }(filePath, mappings)
+	config := writeTemp(t, "config.txt", `
+rsc.io/x https://github.com/rsc/first
+rsc.io/x/ https://github.com/rsc/last
+`)
+	tests := []struct {
+		mode string
+		err  string // or "" if loaded
+		warn bool
+	}{
+		{"error", "duplicate import path rsc.io/x, configured earlier in the same file", false},
+		{"warn", "", true},
+		{"last-wins", "", false},
+	}
+	for _, tt := range tests {
+		setFlag(t, "on-duplicate", tt.mode)
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		err := readConfig(config)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("-on-duplicate=%s: readConfig = %v, want %q", tt.mode, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("-on-duplicate=%s: %v", tt.mode, err)
+			continue
+		}
+		if d, _, _ := resolve("rsc.io/x/"); d == nil || d.VCSRoot != "https://github.com/rsc/last" {
+			t.Errorf("-on-duplicate=%s: resolve(rsc.io/x/) = %+v, want the last couple", tt.mode, d)
+		}
+		if warned := strings.Contains(buf.String(), "warning: duplicate import path rsc.io/x"); warned != tt.warn {
+			t.Errorf("-on-duplicate=%s: warned %v, want %v; log:\n%s", tt.mode, warned, tt.warn, buf.String())
+		}
+	}
+}
+
+func TestMaxMappings(t *testing.T) {
+	// -max-mappings is registered in main, as another name for -max-entries.
+	defer func(old int) { *maxEntries = old }(*maxEntries)
+	defer log.SetOutput(ioutil.Discard)
+	const config = `
+rsc.io/* https://github.com/rsc/*
+example.com/a https://github.com/ex/a
+example.com/*/tools https://github.com/*/tools
+`
+	defer func(path string, m *redirector.Mappings) { filePath, mappings = path, m }(filePath, mappings)
+	path := writeTemp(t, "config.txt", config)
+	*maxEntries = 2
+	err := readConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "too many import couples: limit is 2") {
+		t.Errorf("readFile with 3 couples over -max-mappings=2 = %v, want too many import couples", err)
+	}
+
+	*maxEntries = 3
+	if err := readConfig(path); err != nil || mappings.Len() != 3 {
+		t.Errorf("loaded %d couples at -max-mappings=3 (%v), want 3", mappings.Len(), err)
+	}
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	logMappingCount()
+	if want := "Loaded 3 import couples (2 with wildcards)"; !strings.Contains(buf.String(), want) {
+		t.Errorf("startup log %q, want %q", buf.String(), want)
+	}
+}
+
+func TestCheckImportPath(t *testing.T) {
+	tests := []struct {
+		importPath string
+		err        string // or "" if valid
+	}{
+		{"rsc.io/x86/", ""},
+		{"rsc.io/*/", ""},
+		{"*.example.com/go/", ""},
+		{"localhost:8080/x/", ""},
+		{"example.com/a/b/c/", ""},
+		{"https://rsc.io/x86/", "import path must not have a scheme"},
+		{"rsc.io//x86/", "import path must not have empty elements"},
+		{"rsc.io:http/x86/", "import path has invalid port"},
+		{"rsc.io:70000/x86/", "import path has invalid port"},
+		{"/x86/", "import path must begin with a host name"},
+		{"rsc..io/x86/", "import path must begin with a host name"},
+		{"-rsc.io/x86/", "import path must begin with a host name"},
+		{"rsc_io/x86/", "import path must begin with a host name"},
+		{"a.*.example.com/x/", "import path must begin with a host name"},
+		{"*/x/", "import path must begin with a host name"},
+	}
+	for _, tt := range tests {
+		err := checkImportPath(tt.importPath)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.err)) {
+			t.Errorf("checkImportPath(%q) = %v, want %q", tt.importPath, err, tt.err)
+		}
+	}
+}
+
+func TestReadURL(t *testing.T) {
+	defer func(old string) { loadingFile = old }(loadingFile)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/mappings.txt":
+			io.WriteString(w, "rsc.io/* https://github.com/rsc/*\n")
+		case "/mappings":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"mappings": [{"import": "example.com/x", "repo": "https://github.com/example/x"}]}`)
+		case "/mappings.toml":
+			io.WriteString(w, "[[mapping]]\nimport = \"example.com/y\"\nrepo = \"https://github.com/example/y\"\n")
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer ts.Close()
+
+	for path, importPath := range map[string]string{
+		"/mappings.txt":  "rsc.io/x86",
+		"/mappings":      "example.com/x",
+		"/mappings.toml": "example.com/y",
+	} {
+		m := redirector.NewMappings()
+		if err := readURL(m, ts.URL+path); err != nil {
+			t.Errorf("readURL(%s): %v", path, err)
+			continue
+		}
+		if m.Match(importPath) == nil {
+			t.Errorf("readURL(%s): %s not loaded; got %v", path, importPath, m.All())
+		}
+	}
+
+	err := readURL(redirector.NewMappings(), ts.URL+"/missing")
+	if err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Errorf("readURL of a missing config = %v, want 404 Not Found", err)
+	}
+	ts.Close()
+	if err := readURL(redirector.NewMappings(), ts.URL+"/mappings.txt"); err == nil || !strings.HasPrefix(err.Error(), "fetching config: ") {
+		t.Errorf("readURL with the server down = %v, want a fetch error", err)
+	}
+}
+
+func TestRepoPlaceholder(t *testing.T) {
+	useMappings(t, `
+example.com/* https://git.example.com/mirror/{name}/code
+example.com/*/sub/* https://git.example.com/{name}-mirror/x/{name}.git
+`)
+	for importPath, want := range map[string]string{
+		"example.com/pdf/x":       "https://git.example.com/mirror/pdf/code",
+		"example.com/a/sub/b/pkg": "https://git.example.com/a-mirror/x/b.git",
+	} {
+		if match := mappings.Match(importPath); match == nil || match.VCSRoot != want {
+			t.Errorf("Match(%s) = %+v, want repo %s", importPath, match, want)
+		}
+	}
+
+	tests := []struct {
+		importPath, repo string
+		err              string
+	}{
+		{"example.com/*/*/", "https://git.example.com/{name}/code", "import and repo must have the same wildcards in the same order"},
+		{"example.com/*/", "https://git.example.com/{name}/{name}", "import and repo must have the same wildcards in the same order"},
+		{"example.com/x/", "https://git.example.com/{name}", "either both import and repo must have /* (or {name} in repo) or neither"},
+		{"example.com/*/", "https://git.example.com/code", "either both import and repo must have /* (or {name} in repo) or neither"},
+	}
+	for _, tt := range tests {
+		if err := validateInput(tt.importPath, tt.repo); err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("validateInput(%q, %q) = %v, want %q", tt.importPath, tt.repo, err, tt.err)
+		}
+	}
+}
+
+func TestMaxLineBytes(t *testing.T) {
+	setFlag(t, "max-line-bytes", "64")
+	short := "rsc.io/x86 https://github.com/rsc/x86\n"
+	long := "example.com/x https://github.com/example/" + strings.Repeat("x", 64) + "\n"
+
+	if err := readText(redirector.NewMappings(), strings.NewReader(short)); err != nil {
+		t.Errorf("readText of a %d-byte line: %v", len(short), err)
+	}
+	err := readText(redirector.NewMappings(), strings.NewReader(short+long))
+	if want := "file malformed: line longer than 64 bytes (see -max-line-bytes)"; err == nil || err.Error() != want {
+		t.Errorf("readText of a %d-byte line = %v, want %q", len(long), err, want)
+	}
+}
+
+func TestReadTextReaderError(t *testing.T) {
+	errBroken := errors.New("connection reset")
+	r := io.MultiReader(strings.NewReader("rsc.io/x86 https://github.com/rsc/x86\n"), iotest.ErrReader(errBroken))
+	if err := readText(redirector.NewMappings(), r); err != errBroken {
+		t.Errorf("readText of a failing reader = %v, want %v", err, errBroken)
+	}
+}
+
+func TestConfigDefaultsReread(t *testing.T) {
+	useMappings(t, "")
+	defer func(old map[string]bool) { flagsGiven = old }(flagsGiven)
+	flagsGiven = map[string]bool{}
+	setFlag(t, "vcs", "git")
+	setFlag(t, "default-redirect", "")
+
+	config := func(vcs, redirect string) string {
+		return `{"defaults": {"vcs": "` + vcs + `", "defaultRedirect": "` + redirect + `"},
+			"mappings": [{"import": "example.com/x", "repo": "https://code.example.com/x"}]}`
+	}
+	filePath = writeTemp(t, "config.json", config("hg", "https://example.com/a"))
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if got := mappings.Match("example.com/x").VCS; got != "hg" {
+		t.Errorf("after first load: vcs = %s, want hg", got)
+	}
+	if got := mappings.DefaultRedirect(); got != "https://example.com/a" {
+		t.Errorf("after first load: default redirect = %s, want https://example.com/a", got)
+	}
+
+	if err := ioutil.WriteFile(filePath, []byte(config("bzr", "https://example.com/b")), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if got := mappings.Match("example.com/x").VCS; got != "bzr" {
+		t.Errorf("after reload: vcs = %s, want bzr", got)
+	}
+	if got := mappings.DefaultRedirect(); got != "https://example.com/b" {
+		t.Errorf("after reload: default redirect = %s, want https://example.com/b", got)
+	}
+
+	// A flag given on the command line wins over the config.
+	flagsGiven["vcs"] = true
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if got := mappings.Match("example.com/x").VCS; got != "git" {
+		t.Errorf("with -vcs given: vcs = %s, want git", got)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// A cidrList is a flag.Value holding networks given by repeated CIDR flags.
+type cidrList []*net.IPNet
+
+func (l *cidrList) String() string {
+	var s []string
+	for _, n := range *l {
+		s = append(s, n.String())
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *cidrList) Set(v string) error {
+	_, n, err := net.ParseCIDR(v)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, n)
+	return nil
+}
+
+func (l cidrList) contains(ip net.IP) bool {
+	for _, n := range l {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP address of the client making req.
+// With -trust-proxy, it is the last address in X-Forwarded-For,
+// as added by the proxy in front of the redirector, if present.
+// A header repeated on several lines is read as a single list.
+func clientIP(req *http.Request) net.IP {
+	if *trustProxy {
+		if fwd := strings.Join(req.Header["X-Forwarded-For"], ","); fwd != "" {
+			if i := strings.LastIndex(fwd, ","); i >= 0 {
+				fwd = fwd[i+1:]
+			}
+			return net.ParseIP(strings.TrimSpace(fwd))
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// debugOnly restricts h, a debugging endpoint such as .ping,
+// to clients within the -debug-allow-cidr networks, if any are given.
+// Other clients get 403 Forbidden.
+func debugOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if len(debugAllow) > 0 {
+			if ip := clientIP(req); ip == nil || !debugAllow.contains(ip) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+		}
+		h(w, req)
+	}
+}
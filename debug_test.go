@@ -0,0 +1,109 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDebugAllowCIDR(t *testing.T) {
+	defer func(old cidrList) { debugAllow = old }(debugAllow)
+	debugAllow = nil
+	if err := debugAllow.Set("10.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+	if err := debugAllow.Set("2001:db8::/32"); err != nil {
+		t.Fatal(err)
+	}
+	h := debugOnly(pong)
+
+	tests := []struct {
+		remoteAddr string
+		status     int
+	}{
+		{"10.1.2.3:4567", 200},
+		{"[2001:db8::1]:4567", 200},
+		{"192.0.2.1:4567", http.StatusForbidden},
+		{"[2001:db9::1]:4567", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "http://rsc.io/.ping", nil)
+		req.RemoteAddr = tt.remoteAddr
+		w := httptest.NewRecorder()
+		h(w, req)
+		if w.Code != tt.status {
+			t.Errorf("from %s: status %d, want %d", tt.remoteAddr, w.Code, tt.status)
+		}
+	}
+
+	// Without -debug-allow-cidr, every client is allowed.
+	debugAllow = nil
+	req := httptest.NewRequest("GET", "http://rsc.io/.ping", nil)
+	req.RemoteAddr = "192.0.2.1:4567"
+	w := httptest.NewRecorder()
+	h(w, req)
+	if w.Code != 200 {
+		t.Errorf("without -debug-allow-cidr: status %d, want 200", w.Code)
+	}
+}
+
+func TestDebugAllowTrustProxy(t *testing.T) {
+	defer func(old cidrList) { debugAllow = old }(debugAllow)
+	debugAllow = nil
+	if err := debugAllow.Set("10.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+	h := debugOnly(pong)
+	get := func(fwd ...string) int {
+		req := httptest.NewRequest("GET", "http://rsc.io/.ping", nil)
+		req.RemoteAddr = "10.9.9.9:4567"
+		for _, v := range fwd {
+			req.Header.Add("X-Forwarded-For", v)
+		}
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w.Code
+	}
+
+	// Without -trust-proxy, the header is ignored.
+	if code := get("192.0.2.1"); code != 200 {
+		t.Errorf("X-Forwarded-For 192.0.2.1 without -trust-proxy: status %d, want 200", code)
+	}
+	setFlag(t, "trust-proxy", "true")
+	if code := get("192.0.2.1"); code != http.StatusForbidden {
+		t.Errorf("X-Forwarded-For 192.0.2.1 with -trust-proxy: status %d, want 403", code)
+	}
+	// The proxy appends the address it saw last.
+	if code := get("192.0.2.1, 10.1.2.3"); code != 200 {
+		t.Errorf("X-Forwarded-For 192.0.2.1, 10.1.2.3 with -trust-proxy: status %d, want 200", code)
+	}
+	// A proxy may add its own header line rather than append to the first.
+	if code := get("10.1.2.3", "192.0.2.1"); code != http.StatusForbidden {
+		t.Errorf("X-Forwarded-For lines 10.1.2.3 and 192.0.2.1 with -trust-proxy: status %d, want 403", code)
+	}
+}
+
+func TestDebugHeaders(t *testing.T) {
+	useMappings(t, "rsc.io/* https://github.com/rsc/*\n")
+	const url = "http://rsc.io/x86/x86asm?go-get=1"
+	names := []string{"X-Go-Import-Root", "X-Go-Vcs", "X-Go-Repo"}
+
+	w := doRequest(redirect, "GET", url)
+	for _, name := range names {
+		if got := w.Header().Get(name); got != "" {
+			t.Errorf("without -debug-headers: %s: %q, want none", name, got)
+		}
+	}
+
+	setFlag(t, "debug-headers", "true")
+	w = doRequest(redirect, "GET", url)
+	for i, want := range []string{"rsc.io/x86", "git", "https://github.com/rsc/x86"} {
+		if got := w.Header().Get(names[i]); got != want {
+			t.Errorf("with -debug-headers: %s: %q, want %q", names[i], got, want)
+		}
+	}
+}
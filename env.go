@@ -0,0 +1,73 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/noaleibo1/go-import-redirector/redirector"
+)
+
+// envPrefix begins the names of the environment variables
+// that stand in for flags, as in GIR_ADDR for -addr.
+const envPrefix = "GIR_"
+
+// mappingsEnv names the environment variable holding import couples
+// in the line-based config format, read when no arguments are given.
+const mappingsEnv = envPrefix + "MAPPINGS"
+
+// flagEnv returns the name of the environment variable for the named flag.
+func flagEnv(name string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// setFlagsFromEnv sets each flag of fs not given on the command line
+// from its environment variable, if that is set. A flag counts as given
+// if it or another name for the same setting, as -max-mappings is for
+// -max-entries, was given on the command line or set from the environment
+// before it: the first of those names in lexical order.
+func setFlagsFromEnv(fs *flag.FlagSet) error {
+	given := map[interface{}]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		given[flagTarget(f)] = true
+	})
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if given[flagTarget(f)] || err != nil {
+			return
+		}
+		v, ok := os.LookupEnv(flagEnv(f.Name))
+		if !ok {
+			return
+		}
+		if e := fs.Set(f.Name, v); e != nil {
+			err = fmt.Errorf("invalid $%s: %v", flagEnv(f.Name), e)
+		}
+		given[flagTarget(f)] = true
+	})
+	return err
+}
+
+// flagTarget returns the variable that f sets, which is shared by
+// all names for the same setting, or, failing that, the name of f.
+func flagTarget(f *flag.Flag) interface{} {
+	if v := reflect.ValueOf(f.Value); v.Kind() == reflect.Ptr {
+		return v.Pointer()
+	}
+	return f.Name
+}
+
+// readMappingsEnv loads import couples into m from the mappingsEnv variable.
+func readMappingsEnv(m *redirector.Mappings) error {
+	loadingFile = "$" + mappingsEnv
+	if err := readText(m, strings.NewReader(os.Getenv(mappingsEnv))); err != nil {
+		return fmt.Errorf("$%s: %v", mappingsEnv, err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+// envFlags returns a flag set like that of the command line, with
+// -max-entries also named -max-mappings, parsed from args.
+func envFlags(t *testing.T, args ...string) (fs *flag.FlagSet, maxEntries *int, addr *string, timeout *time.Duration) {
+	t.Helper()
+	fs = flag.NewFlagSet("go-import-redirector", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	maxEntries = fs.Int("max-entries", 0, "")
+	fs.IntVar(maxEntries, "max-mappings", 0, "")
+	addr = fs.String("addr", ":http", "")
+	timeout = fs.Duration("read-timeout", 10*time.Second, "")
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return fs, maxEntries, addr, timeout
+}
+
+func TestFlagEnv(t *testing.T) {
+	for name, want := range map[string]string{
+		"addr":          "GIR_ADDR",
+		"cache-max-age": "GIR_CACHE_MAX_AGE",
+		"max-mappings":  "GIR_MAX_MAPPINGS",
+	} {
+		if got := flagEnv(name); got != want {
+			t.Errorf("flagEnv(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSetFlagsFromEnv(t *testing.T) {
+	t.Setenv("GIR_ADDR", ":8080")
+	t.Setenv("GIR_READ_TIMEOUT", "3s")
+	fs, _, addr, timeout := envFlags(t)
+	if err := setFlagsFromEnv(fs); err != nil {
+		t.Fatal(err)
+	}
+	if *addr != ":8080" || *timeout != 3*time.Second {
+		t.Errorf("from the environment, -addr=%s -read-timeout=%v, want :8080 3s", *addr, *timeout)
+	}
+
+	// The command line takes precedence.
+	fs, _, addr, _ = envFlags(t, "-addr=:9090")
+	if err := setFlagsFromEnv(fs); err != nil {
+		t.Fatal(err)
+	}
+	if *addr != ":9090" {
+		t.Errorf("-addr=%s, want :9090 from the command line", *addr)
+	}
+}
+
+func TestSetFlagsFromEnvAliases(t *testing.T) {
+	// The variable for another name of a flag given on the command line
+	// does not override it.
+	t.Setenv("GIR_MAX_MAPPINGS", "1")
+	fs, maxEntries, _, _ := envFlags(t, "-max-entries=5")
+	if err := setFlagsFromEnv(fs); err != nil {
+		t.Fatal(err)
+	}
+	if *maxEntries != 5 {
+		t.Errorf("-max-entries=5 with GIR_MAX_MAPPINGS=1 gives %d, want 5", *maxEntries)
+	}
+
+	fs, maxEntries, _, _ = envFlags(t)
+	if err := setFlagsFromEnv(fs); err != nil {
+		t.Fatal(err)
+	}
+	if *maxEntries != 1 {
+		t.Errorf("GIR_MAX_MAPPINGS=1 alone gives %d, want 1", *maxEntries)
+	}
+
+	// Of two variables for the same flag, the first name wins.
+	t.Setenv("GIR_MAX_ENTRIES", "7")
+	fs, maxEntries, _, _ = envFlags(t)
+	if err := setFlagsFromEnv(fs); err != nil {
+		t.Fatal(err)
+	}
+	if *maxEntries != 7 {
+		t.Errorf("GIR_MAX_ENTRIES=7 GIR_MAX_MAPPINGS=1 gives %d, want 7", *maxEntries)
+	}
+}
+
+func TestSetFlagsFromEnvInvalid(t *testing.T) {
+	t.Setenv("GIR_READ_TIMEOUT", "soon")
+	fs, _, _, _ := envFlags(t)
+	if err := setFlagsFromEnv(fs); err == nil || !strings.HasPrefix(err.Error(), "invalid $GIR_READ_TIMEOUT") {
+		t.Errorf("setFlagsFromEnv with GIR_READ_TIMEOUT=soon = %v, want invalid $GIR_READ_TIMEOUT", err)
+	}
+}
+
+func TestReadMappingsEnv(t *testing.T) {
+	t.Setenv(mappingsEnv, "rsc.io/* https://github.com/rsc/*\n9fans.net/go https://github.com/9fans/go\n")
+	m := newMappings()
+	if err := readMappingsEnv(m); err != nil {
+		t.Fatal(err)
+	}
+	if match := m.Match("rsc.io/x86"); match == nil || match.VCSRoot != "https://github.com/rsc/x86" {
+		t.Errorf("Match(rsc.io/x86) = %+v", match)
+	}
+	if m.Match("9fans.net/go/draw") == nil {
+		t.Error("9fans.net/go not loaded")
+	}
+
+	t.Setenv(mappingsEnv, "rsc.io/x86\n")
+	if err := readMappingsEnv(newMappings()); err == nil {
+		t.Error("readMappingsEnv succeeded with a malformed line")
+	}
+}
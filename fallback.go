@@ -0,0 +1,46 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+// fallbackHandler, if not nil, serves requests that match no couple.
+var fallbackHandler http.Handler
+
+// newFallback returns a handler that sends requests to the server at rawurl,
+// either by redirecting the client there or, for mode proxy, by forwarding
+// the request with its original Host header.
+func newFallback(rawurl, mode string) (http.Handler, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("fallback %q must be an http or https URL", rawurl)
+	}
+	switch mode {
+	case "redirect":
+		base := strings.TrimSuffix(u.String(), "/")
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			target := base + req.URL.Path
+			if req.URL.RawQuery != "" {
+				target += "?" + req.URL.RawQuery
+			}
+			w.Header().Set("Cache-Control", "no-store")
+			http.Redirect(w, req, target, *redirectStatus)
+		}), nil
+	case "proxy":
+		p := httputil.NewSingleHostReverseProxy(u)
+		p.ErrorLog = requestLog
+		return p, nil
+	}
+	return nil, fmt.Errorf("invalid -fallback-mode %q: must be redirect or proxy", mode)
+}
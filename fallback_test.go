@@ -0,0 +1,63 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// useFallback sets -fallback and -fallback-mode, and the handler main
+// would make of them, until the test ends.
+func useFallback(t *testing.T, rawurl, mode string) {
+	t.Helper()
+	setFlag(t, "fallback", rawurl)
+	setFlag(t, "fallback-mode", mode)
+	h, err := newFallback(rawurl, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := fallbackHandler
+	fallbackHandler = h
+	t.Cleanup(func() { fallbackHandler = old })
+}
+
+func TestFallback(t *testing.T) {
+	const config = "rsc.io/x86 https://github.com/rsc/x86\n"
+
+	useMappings(t, config)
+	if w := doRequest(newHandler(), "GET", "http://rsc.io/pdf?go-get=1"); w.Code != 404 {
+		t.Errorf("rsc.io/pdf without -fallback: status %d, want 404", w.Code)
+	}
+
+	t.Run("redirect", func(t *testing.T) {
+		useFallback(t, "https://old.example.com/", "redirect")
+		useMappings(t, config)
+		h := newHandler()
+		w := doRequest(h, "GET", "http://rsc.io/pdf?go-get=1")
+		if loc := w.Header().Get("Location"); w.Code != 302 || loc != "https://old.example.com/pdf?go-get=1" {
+			t.Errorf("rsc.io/pdf: %d to %q, want 302 to https://old.example.com/pdf?go-get=1", w.Code, loc)
+		}
+		// Configured paths are still served here.
+		if w := doRequest(h, "GET", "http://rsc.io/x86?go-get=1"); w.Code != 200 || goImportTag(w.Body.String(), "rsc.io/x86") == "" {
+			t.Errorf("rsc.io/x86: status %d, want 200 with a go-import tag", w.Code)
+		}
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(w, "%s%s?%s", req.Host, req.URL.Path, req.URL.RawQuery)
+		}))
+		defer backend.Close()
+		useFallback(t, backend.URL, "proxy")
+		useMappings(t, config)
+		w := doRequest(newHandler(), "GET", "http://rsc.io/pdf?go-get=1")
+		if body := w.Body.String(); w.Code != 200 || body != "rsc.io/pdf?go-get=1" {
+			t.Errorf("rsc.io/pdf: %d %q, want the backend's 200 for rsc.io/pdf?go-get=1", w.Code, body)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// proxyModule returns the module path of a module proxy request for the
+// URL path, such as rsc.io/x86 for /rsc.io/x86/@v/list,
+// /rsc.io/x86/@v/v0.1.0.info, or /rsc.io/x86/@latest, with the escaping
+// of its upper-case letters undone. It reports false for any other path.
+func proxyModule(path string) (string, bool) {
+	path = strings.TrimPrefix(path, "/")
+	if i := strings.Index(path, "/@v/"); i > 0 {
+		return unescapeModule(path[:i])
+	}
+	if strings.HasSuffix(path, "/@latest") {
+		return unescapeModule(strings.TrimSuffix(path, "/@latest"))
+	}
+	return "", false
+}
+
+// unescapeModule undoes the escaping of a module path in module proxy
+// requests, in which each upper-case letter is written as ! followed by
+// the lower-case letter, as in github.com/!azure for github.com/Azure.
+// It reports false if path is not a valid escaped path.
+func unescapeModule(path string) (string, bool) {
+	var b bytes.Buffer
+	bang := false
+	for _, r := range path {
+		switch {
+		case bang:
+			if !unicode.IsLower(r) {
+				return "", false
+			}
+			b.WriteRune(unicode.ToUpper(r))
+			bang = false
+		case r == '!':
+			bang = true
+		case unicode.IsUpper(r):
+			return "", false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if bang || !strings.Contains(path, ".") {
+		return "", false
+	}
+	return b.String(), true
+}
+
+// proxyHint handles req if -proxy-hints is set and it is a module proxy
+// request: one whose URL path is a module path followed by a proxy endpoint,
+// as sent by a go command whose GOPROXY names the redirector. It redirects
+// the request to the same path on the -proxy-upstream proxy if the module
+// is configured, or fails with 404 Not Found otherwise. The host of the
+// request plays no part: that is the redirector itself, named in GOPROXY.
+// It reports whether it handled req.
+func proxyHint(w http.ResponseWriter, req *http.Request) bool {
+	if !*proxyHints {
+		return false
+	}
+	module, ok := proxyModule(req.URL.Path)
+	if !ok {
+		return false
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	if *proxyUpstream == "" || mappings.Match(module) == nil {
+		http.NotFound(w, req)
+		return true
+	}
+	http.Redirect(w, req, strings.TrimSuffix(*proxyUpstream, "/")+req.URL.EscapedPath(), *redirectStatus)
+	return true
+}
@@ -0,0 +1,63 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestProxyModule(t *testing.T) {
+	tests := []struct {
+		path   string
+		module string
+		ok     bool
+	}{
+		{"/rsc.io/x86/@v/list", "rsc.io/x86", true},
+		{"/rsc.io/x86/@v/v0.1.0.info", "rsc.io/x86", true},
+		{"/rsc.io/x86/@latest", "rsc.io/x86", true},
+		{"/github.com/!azure/go/@v/list", "github.com/Azure/go", true},
+		{"/github.com/Azure/go/@v/list", "", false},
+		{"/github.com/!/go/@v/list", "", false},
+		{"/x86/@v/list", "", false},
+		{"/rsc.io/x86", "", false},
+		{"/x86/x86asm", "", false},
+	}
+	for _, tt := range tests {
+		module, ok := proxyModule(tt.path)
+		if module != tt.module || ok != tt.ok {
+			t.Errorf("proxyModule(%q) = %q, %v, want %q, %v", tt.path, module, ok, tt.module, tt.ok)
+		}
+	}
+}
+
+func TestProxyHints(t *testing.T) {
+	useMappings(t, "rsc.io/* https://github.com/rsc/*\n")
+	setFlag(t, "proxy-hints", "true")
+
+	tests := []struct {
+		url      string
+		status   int
+		location string
+	}{
+		// GOPROXY=https://rsc.io: the module path follows the host.
+		{"http://rsc.io/rsc.io/x86/@v/list", 302, "https://proxy.golang.org/rsc.io/x86/@v/list"},
+		{"http://rsc.io/rsc.io/x86/@v/v1.0.0.mod", 302, "https://proxy.golang.org/rsc.io/x86/@v/v1.0.0.mod"},
+		{"http://rsc.io/rsc.io/x86/@latest", 302, "https://proxy.golang.org/rsc.io/x86/@latest"},
+		// The redirector under another name, not itself a configured host.
+		{"http://proxy.example.com/rsc.io/x86/@v/list", 302, "https://proxy.golang.org/rsc.io/x86/@v/list"},
+		{"http://rsc.io/example.org/y/@v/list", 404, ""},
+		// A package path is still served its page.
+		{"http://rsc.io/x86/x86asm?go-get=1", 200, ""},
+	}
+	for _, tt := range tests {
+		w := doRequest(redirect, "GET", tt.url)
+		if w.Code != tt.status || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s: %d %q, want %d %q", tt.url, w.Code, w.Header().Get("Location"), tt.status, tt.location)
+		}
+	}
+
+	setFlag(t, "proxy-upstream", "")
+	if w := doRequest(redirect, "GET", "http://rsc.io/rsc.io/x86/@v/list"); w.Code != 404 {
+		t.Errorf("without -proxy-upstream, status = %d, want 404", w.Code)
+	}
+}
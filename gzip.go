@@ -0,0 +1,111 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// gzipMinSize is the size below which responses are not compressed.
+// The pages served to the go command are smaller than this,
+// and gain nothing from compression.
+const gzipMinSize = 1024
+
+// compress returns a handler that calls h, compressing its responses with gzip
+// for clients that accept it, once they reach gzipMinSize bytes.
+func compress(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if req.Method == "HEAD" || !acceptsGzip(req) {
+			h.ServeHTTP(w, req)
+			return
+		}
+		gw := &gzipWriter{ResponseWriter: w}
+		defer gw.close()
+		h.ServeHTTP(gw, req)
+	})
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of req allows gzip.
+func acceptsGzip(req *http.Request) bool {
+	for _, enc := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		enc = strings.TrimSpace(enc)
+		if enc == "gzip" || strings.HasPrefix(enc, "gzip;") && !strings.HasSuffix(strings.Replace(enc, " ", "", -1), ";q=0") {
+			return true
+		}
+	}
+	return false
+}
+
+// A gzipWriter is an http.ResponseWriter that holds back the response
+// until it reaches gzipMinSize bytes, then sends it compressed.
+// Smaller responses are sent as they are by close.
+type gzipWriter struct {
+	http.ResponseWriter
+	status int
+	buf    []byte
+	gz     *gzip.Writer
+	sent   bool // header sent; the rest of the body goes to gz, or as is if nil
+}
+
+func (w *gzipWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.sent {
+		if w.gz != nil {
+			return w.gz.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) < gzipMinSize {
+		return len(b), nil
+	}
+
+	// Leave alone a response that is already encoded, as from a proxied -fallback.
+	h := w.Header()
+	if h.Get("Content-Encoding") == "" {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", "gzip")
+		// The compressed page is not byte for byte the one the ETag was computed for.
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.sent = true
+	buf := w.buf
+	w.buf = nil
+	if _, err := w.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// close finishes the response.
+func (w *gzipWriter) close() {
+	if w.sent {
+		if w.gz != nil {
+			w.gz.Close()
+		}
+		return
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if len(w.buf) > 0 {
+		w.ResponseWriter.Write(w.buf)
+	}
+}
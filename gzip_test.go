@@ -0,0 +1,84 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	tests := []struct {
+		size           int
+		acceptEncoding string
+		compressed     bool
+	}{
+		{gzipMinSize - 1, "gzip", false},
+		{gzipMinSize, "gzip", true},
+		{4 * gzipMinSize, "gzip, deflate", true},
+		{4 * gzipMinSize, "", false},
+		{4 * gzipMinSize, "deflate", false},
+		{4 * gzipMinSize, "gzip;q=0", false},
+		{4 * gzipMinSize, "gzip;q=0.5", true},
+	}
+	for _, tt := range tests {
+		body := strings.Repeat("x", tt.size)
+		h := compress(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("ETag", `"abc"`)
+			w.Write([]byte(body))
+		}))
+		req := httptest.NewRequest("GET", "http://rsc.io/x86", nil)
+		req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+			t.Errorf("%d bytes, Accept-Encoding %q: Vary %q, want Accept-Encoding", tt.size, tt.acceptEncoding, vary)
+		}
+		if enc := w.Header().Get("Content-Encoding"); (enc == "gzip") != tt.compressed {
+			t.Errorf("%d bytes, Accept-Encoding %q: Content-Encoding %q, want compressed %v", tt.size, tt.acceptEncoding, enc, tt.compressed)
+			continue
+		}
+		got := w.Body.String()
+		wantETag := `"abc"`
+		if tt.compressed {
+			zr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ioutil.ReadAll(zr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = string(b)
+			wantETag = `W/"abc"`
+		}
+		if got != body {
+			t.Errorf("%d bytes, Accept-Encoding %q: got %d bytes back", tt.size, tt.acceptEncoding, len(got))
+		}
+		if etag := w.Header().Get("ETag"); etag != wantETag {
+			t.Errorf("%d bytes, Accept-Encoding %q: ETag %s, want %s", tt.size, tt.acceptEncoding, etag, wantETag)
+		}
+	}
+}
+
+func TestGzipGoGetPage(t *testing.T) {
+	setFlag(t, "gzip", "true")
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	req := httptest.NewRequest("GET", "http://rsc.io/x86?go-get=1", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	newHandler()(w, req)
+	if enc := w.Header().Get("Content-Encoding"); w.Code != 200 || enc != "" {
+		t.Errorf("go-get page with -gzip: %d with Content-Encoding %q, want 200 uncompressed", w.Code, enc)
+	}
+	if goImportTag(w.Body.String(), "rsc.io/x86") == "" {
+		t.Errorf("go-get page with -gzip: no go-import tag in %q", w.Body.String())
+	}
+}
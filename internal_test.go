@@ -0,0 +1,167 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInternalEndpointCollision(t *testing.T) {
+	useMappings(t, "example.com/x https://github.com/example/x\n")
+
+	// By default the internal endpoint wins over the package of that name.
+	w := doRequest(newHandler(), "GET", "http://example.com/x/.ping?go-get=1")
+	if w.Code != 200 || w.Body.String() != "pong" {
+		t.Errorf("example.com/x/.ping without -internal-host: %d %q, want pong", w.Code, w.Body.String())
+	}
+
+	setFlag(t, "internal-host", "admin.example.com")
+	h := newHandler()
+	// Import paths have no elements starting with a dot,
+	// so .ping is then not found on other hosts.
+	w = doRequest(h, "GET", "http://example.com/x/.ping?go-get=1")
+	if w.Code != 404 || strings.Contains(w.Body.String(), "pong") {
+		t.Errorf("example.com/x/.ping with -internal-host: %d %q, want 404", w.Code, w.Body.String())
+	}
+	w = doRequest(h, "GET", "http://admin.example.com/.ping")
+	if w.Code != 200 || w.Body.String() != "pong" {
+		t.Errorf("admin.example.com/.ping: %d %q, want pong", w.Code, w.Body.String())
+	}
+}
+
+func TestPongContentType(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	w := doRequest(newHandler(), "GET", "http://rsc.io/x86/.ping")
+	if got := w.Header().Get("Content-Type"); w.Body.String() != "pong" || got != "text/plain; charset=utf-8" {
+		t.Errorf("rsc.io/x86/.ping: %q with Content-Type %q, want pong with text/plain; charset=utf-8", w.Body.String(), got)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\nrsc.io/pdf https://github.com/rsc/pdf\n")
+	setFlag(t, "tls", "true")
+	w := doRequest(newHandler(), "GET", "http://rsc.io/x86/.status")
+	if ct := w.Header().Get("Content-Type"); w.Code != 200 || ct != "application/json" {
+		t.Fatalf("rsc.io/x86/.status: %d with Content-Type %q, want 200 with application/json", w.Code, ct)
+	}
+	var st struct {
+		Version  string
+		Commit   string
+		Mappings int
+		TLS      bool
+		Uptime   string
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil {
+		t.Fatalf("rsc.io/x86/.status: %v in %q", err, w.Body.String())
+	}
+	if st.Version != version || st.Commit != commit || st.Mappings != 2 || !st.TLS {
+		t.Errorf("rsc.io/x86/.status = %+v, want version %s, commit %s, 2 mappings and tls", st, version, commit)
+	}
+	if _, err := time.ParseDuration(st.Uptime); err != nil {
+		t.Errorf("rsc.io/x86/.status uptime %q: %v", st.Uptime, err)
+	}
+}
+
+func TestRobots(t *testing.T) {
+	defer func(old []byte) { robotsTxt = old }(robotsTxt)
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	w := doRequest(newHandler(), "GET", "http://rsc.io/robots.txt")
+	if w.Code != 200 || w.Body.String() != "User-agent: *\nDisallow: /\n" {
+		t.Errorf("built-in robots.txt: %d %q, want everything disallowed", w.Code, w.Body.String())
+	}
+
+	const custom = "User-agent: *\nDisallow: /private/\n"
+	setFlag(t, "robots", writeTemp(t, "robots.txt", custom))
+	if err := readStaticFiles(); err != nil {
+		t.Fatal(err)
+	}
+	w = doRequest(newHandler(), "GET", "http://rsc.io/robots.txt")
+	if ct := w.Header().Get("Content-Type"); w.Code != 200 || w.Body.String() != custom || ct != "text/plain; charset=utf-8" {
+		t.Errorf("-robots file: %d %q with Content-Type %q, want the file as text/plain", w.Code, w.Body.String(), ct)
+	}
+}
+
+func TestPingPath(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	if w := doRequest(newHandler(), "GET", "http://rsc.io/x86/.ping"); w.Code != 200 || w.Body.String() != "pong" {
+		t.Errorf("default .ping: %d %q, want pong", w.Code, w.Body.String())
+	}
+
+	setFlag(t, "ping-path", ".healthz")
+	h := newHandler()
+	if w := doRequest(h, "GET", "http://rsc.io/x86/.healthz"); w.Code != 200 || w.Body.String() != "pong" {
+		t.Errorf("-ping-path=.healthz: %d %q, want pong", w.Code, w.Body.String())
+	}
+	if w := doRequest(h, "GET", "http://rsc.io/x86/.ping"); w.Body.String() == "pong" {
+		t.Errorf("-ping-path=.healthz: .ping still answered")
+	}
+
+	setFlag(t, "no-ping", "true")
+	h = newHandler()
+	if w := doRequest(h, "GET", "http://rsc.io/x86/.healthz"); w.Body.String() == "pong" {
+		t.Errorf("-no-ping: ping path still answered")
+	}
+	// The other internal endpoints stay.
+	if w := doRequest(h, "GET", "http://rsc.io/x86/.status"); w.Code != 200 {
+		t.Errorf("-no-ping: .status: status %d, want 200", w.Code)
+	}
+}
+
+func TestFavicon(t *testing.T) {
+	defer func(old []byte) { faviconICO = old }(faviconICO)
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	if w := doRequest(newHandler(), "GET", "http://rsc.io/favicon.ico"); w.Code != 204 || w.Body.Len() != 0 {
+		t.Errorf("favicon.ico without -favicon: %d with %d-byte body, want 204", w.Code, w.Body.Len())
+	}
+
+	const ico = "\x00\x00\x01\x00\x01\x00\x10\x10\x00\x00"
+	setFlag(t, "favicon", writeTemp(t, "favicon.ico", ico))
+	if err := readStaticFiles(); err != nil {
+		t.Fatal(err)
+	}
+	w := doRequest(newHandler(), "GET", "http://rsc.io/favicon.ico")
+	if ct := w.Header().Get("Content-Type"); w.Code != 200 || w.Body.String() != ico || ct != "image/x-icon" {
+		t.Errorf("favicon.ico with -favicon: %d %q with Content-Type %q, want the file as image/x-icon", w.Code, w.Body.String(), ct)
+	}
+}
+
+func TestPingDuringDrain(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	atomic.StoreInt32(&draining, 1)
+	defer atomic.StoreInt32(&draining, 0)
+	h := newHandler()
+
+	if w := doRequest(h, "GET", "http://rsc.io/x86/.ping"); w.Code != 503 {
+		t.Errorf(".ping while draining: %d %q, want 503", w.Code, w.Body.String())
+	}
+	w := doRequest(h, "GET", "http://rsc.io/x86/x86asm?go-get=1")
+	if got := goImportTag(w.Body.String(), "rsc.io/x86"); w.Code != 200 || got != "rsc.io/x86 git https://github.com/rsc/x86" {
+		t.Errorf("page while draining: %d with go-import tag %q, want it served", w.Code, got)
+	}
+}
+
+func TestIndexPage(t *testing.T) {
+	defer func(old []byte) { indexPage = old }(indexPage)
+	const index = "<html><body>Packages of rsc.io</body></html>\n"
+	setFlag(t, "index", writeTemp(t, "index.html", index))
+	if err := readStaticFiles(); err != nil {
+		t.Fatal(err)
+	}
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	h := newHandler()
+
+	w := doRequest(h, "GET", "http://rsc.io/")
+	if ct := w.Header().Get("Content-Type"); w.Code != 200 || w.Body.String() != index || ct != "text/html; charset=utf-8" {
+		t.Errorf("rsc.io/ with -index: %d %q with Content-Type %q, want the index page", w.Code, w.Body.String(), ct)
+	}
+	w = doRequest(h, "GET", "http://rsc.io/x86/x86asm")
+	if got := goImportTag(w.Body.String(), "rsc.io/x86"); w.Code != 200 || got != "rsc.io/x86 git https://github.com/rsc/x86" || !strings.Contains(w.Body.String(), `http-equiv="refresh"`) {
+		t.Errorf("rsc.io/x86/x86asm with -index: %d %q, want the redirect page", w.Code, w.Body.String())
+	}
+}
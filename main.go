@@ -39,7 +39,113 @@
 //
 // Note that the wildcard element (x86) has been included in the Git repo path.
 //
+// The wildcard may also stand for an interior element of both paths.
+// For example, if invoked as:
+//
+//	go-import-redirector example.com/team/*/pkg https://git.example.com/team/*/pkg
+//
+// then example.com/team/red/pkg/util is served from the repo
+// https://git.example.com/team/red/pkg, while example.com/team/red,
+// which stops short of the pkg element, is not found.
+//
+// Several * elements may be given, each matching one path element.
+// They are substituted into repo in order, so with
+//
+//	go-import-redirector 'example.com/*/*' 'https://git.example.com/*/*'
+//
+// example.com/red/tools/cmd is served from https://git.example.com/red/tools,
+// while example.com/red, which has fewer elements than the template, is not found.
+// The import path and repo must have the same number of wildcards.
+//
+// In the repo, a * element may instead be written as the placeholder {name},
+// which may also stand for just part of an element. The placeholders and
+// * elements of the repo are replaced in order by the elements matched by
+// the wildcards of the import path, so that with
+//
+//	go-import-redirector 'example.com/*' 'https://git.example.com/mirror/{name}/code.git'
+//
+// example.com/tools is served from https://git.example.com/mirror/tools/code.git,
+// and with https://git.example.com/go-{name}.git, from
+// https://git.example.com/go-tools.git.
+//
+// A final ** element in both paths matches all the remaining elements of
+// the import path, for repos nested at varying depths. For example, with
+//
+//	go-import-redirector example.com/** https://git.example.com/**
+//
+// example.com/a/b/c is served from the repo https://git.example.com/a/b/c.
+// Since the match takes in every element, a package within such a repo
+// cannot be told apart from a deeper repo; the depth option described below
+// limits the number of elements matched, so that with depth=2 example.com/a/b/c
+// is served from https://git.example.com/a/b, with c a package in that repo.
+//
+// The repo may be abbreviated for the common hosting sites:
+// gh:org/repo stands for https://github.com/org/repo,
+// gl:org/repo for https://gitlab.com/org/repo, and
+// bb:org/repo for https://bitbucket.org/org/repo.
+//
+// If invoked with a single argument, go-import-redirector instead reads
+// import couples from the named file, one "<import> <repo>" pair per line.
+// References to environment variables, written $VAR or ${VAR}, are expanded
+// in each field, so that for example
+//
+//	rsc.io/x86 ${REPO_BASE}/x86
+//
+// can be shared between deployments that set REPO_BASE differently.
+// A repo that no longer looks like a full URL after expansion (as happens
+// when the variable is unset) is reported as an error.
+//
+// For containers, which are more easily configured through the environment
+// than through files, each flag may instead be given as an environment
+// variable named after it with a GIR_ prefix, in upper case, and with
+// underscores for dashes, such as GIR_ADDR for -addr or GIR_CACHE_MAX_AGE
+// for -cache-max-age. A flag given on the command line takes precedence over
+// its variable, and over that of any other name for it, so that -max-entries
+// is not overridden by GIR_MAX_MAPPINGS. Invoked without arguments, go-import-redirector reads its
+// import couples from the GIR_MAPPINGS variable, in the format of a config file:
+//
+//	GIR_MAPPINGS='rsc.io/* https://github.com/rsc/*
+//	9fans.net/go https://github.com/9fans/go' go-import-redirector
+//
+// When the import roots of several couples match a request, as with
+// example.com/foo and example.com/foo/bar for example.com/foo/bar/baz,
+// the longest root wins, whether or not it came from a wildcard;
+// between roots of equal length, one without a wildcard wins.
+// So with both rsc.io/x86 and rsc.io/* configured, rsc.io/x86 and the packages
+// below it are always served from the repo given for rsc.io/x86. Since such
+// an overlap is more often a mistake than not, it is logged as a warning
+// at startup and on each reload.
+//
 // The -addr option specifies the HTTP address to serve (default ``:http'').
+// An address of the form unix:/path/to/socket serves on a Unix domain socket
+// instead, for use behind a reverse proxy; the socket file is removed on exit.
+// A TCP address may name a specific IPv4 or IPv6 address to listen on,
+// as in 127.0.0.1:80 or [::1]:8080. The -net option restricts listening
+// to IPv4 (tcp4) or IPv6 (tcp6) rather than either (tcp, the default).
+//
+// The -base-path option serves the redirector below a path, for a reverse
+// proxy that passes it requests such as example.com/vanity/rsc.io/x86
+// unchanged. The base path is stripped from each request before it is
+// matched against the import paths, and added back to redirects to other
+// paths of the same server. Requests outside the base path get 404 Not Found.
+//
+// The -systemd option serves on the socket passed by systemd socket activation
+// in place of listening on -addr (or, with -tls, -tls-addr). If systemd
+// passed no socket, go-import-redirector listens on the address as usual.
+//
+// The -read-timeout, -write-timeout, and -idle-timeout options bound the time
+// spent reading a request (default 5s), writing a response (default 10s),
+// and waiting for the next request on a keep-alive connection (default 2m),
+// so that slow or idle clients cannot tie up connections indefinitely.
+// They apply to both HTTP and HTTPS serving.
+//
+// The -access-log option names a file to which a line is appended for each
+// request served, in the Apache Combined Log Format, or - for standard output.
+//
+// The -max-header-bytes option limits the size of request headers
+// (default 16kB); larger requests are rejected with 431 Request Header Fields Too Large.
+// The -max-body-bytes option limits the size of request bodies (default 1kB),
+// which go get never sends; larger requests are rejected with 413 Request Entity Too Large.
 //
 // The -tls option causes go-import-redirector to serve HTTPS on port 443,
 // loading an X.509 certificate and key pair from files in the current directory
@@ -48,8 +154,463 @@
 // Like for http.ListenAndServeTLS, the certificate file should contain the
 // concatenation of the server's certificate and the signing certificate authority's certificate.
 //
+// The -tls-addr option changes the HTTPS address from the default ``:https''.
+// When serving HTTPS, the -addr address redirects plain HTTP requests to HTTPS;
+// it can be set to the empty string (-addr=) to leave port 80 to another server.
+//
+// The -acme option chooses the client obtaining certificates from Let's Encrypt
+// when serving HTTPS: letsencrypt (the default), or autocert, which uses
+// golang.org/x/crypto/acme/autocert, caches certificates in the directory
+// named by -acme-cache-dir (default autocert-cache), and registers the
+// account with the email address given by -acme-email, if any. Either way,
+// certificates are only requested for the hosts configured at startup,
+// so that a client cannot obtain one for an arbitrary name through SNI.
+// With autocert, the -addr server also answers the http-01 challenges
+// that Let's Encrypt uses to check control of each host.
+//
 // The -vcs option specifies the version control system, git, hg, or svn (default ``git'').
 //
+// Each line of a config file may end with options of the form key=value.
+// The cache-control option sets the Cache-Control header sent for the
+// couple's pages, with commas separating directives (for example,
+// cache-control=public,max-age=600). Without it, a ref in the repo URL,
+// given as a fragment (#v1.2.0) or a ref query parameter (?ref=master),
+// selects a default: refs naming a tag or commit are cached for a day,
+// while refs naming a branch are cached for five minutes.
+//
+// The ref option names a branch, tag, or commit to append to the repo URL
+// in the go-import tag as a fragment, for tools that understand one.
+// The go command itself does not interpret the fragment.
+// A repo URL given with a fragment, as in https://github.com/rsc/x86#v1.0.0,
+// is served exactly as written, and is equivalent to ref=v1.0.0.
+//
+// The depth option limits the number of path elements that the ** element
+// of a couple matches, as in depth=2.
+//
+// The docs-host option names the host serving documentation for the
+// couple's packages in place of -docsite, optionally followed by a path,
+// as in docs-host=wiki.example.com/go, which sends rsc.io/x86 readers to
+// https://wiki.example.com/go/rsc.io/x86. A full URL, such as
+// http://wiki.internal/go, may be given for sites not served over HTTPS.
+//
+// The no-docs option, as in no-docs=true, omits the redirect to the
+// documentation from the couple's pages, like -no-docs-redirect does for
+// all couples. The redirect-status option sets the status, 301 or 302,
+// of the redirects sent for the couple in place of -redirect-status.
+//
+// A page lists, after the go-import tag for its import root, the tags for
+// any other couples whose import paths lie below that root, such as
+// example.com/tools within example.com, as for the modules of a monorepo.
+// The go command uses only the tag whose path is a prefix of the one it is
+// looking up, so each still chooses the right repo.
+//
+// The subdir option declares that the module rooted at the import path
+// lives in a subdirectory of the repo, as in a repo holding several modules.
+// For example, the line
+//
+//	example.com/tools https://github.com/example/monorepo subdir=tools
+//
+// serves the tag
+//
+//	<meta name="go-import" content="example.com/tools git https://github.com/example/monorepo tools">
+//
+// so that ``go get'' checks out the whole repo but finds the module in its
+// tools directory. The import path stays the module path, including for
+// packages within it. The subdirectory field requires Go 1.25 or later.
+//
+// For a wildcard couple, the subdir may hold * elements or {name}
+// placeholders, replaced by the elements matched by the wildcards of
+// the import path that the repo leaves over, so that every module of
+// a monorepo can be served by one line:
+//
+//	example.com/* https://github.com/example/monorepo subdir=modules/{name}
+//
+// serves example.com/tools from the modules/tools directory of the monorepo.
+//
+// The hosts option lets one wildcard couple route its import paths to repos
+// on different hosts. It lists elem=host pairs, separated by commas, and
+// the repo holds the placeholder {host}, replaced by the host listed for
+// the element matched by the first wildcard of the import path. That element
+// is not otherwise substituted into the repo. The host listed for * is used
+// for elements not listed; without one, their import paths are not found.
+// For example,
+//
+//	example.com/*/* https://{host}/* hosts=aws=github.com/aws-mirror,gcp=github.com/gcp-mirror,*=github.com/mirror
+//
+// serves example.com/aws/sdk from https://github.com/aws-mirror/sdk,
+// example.com/gcp/sdk from https://github.com/gcp-mirror/sdk,
+// and example.com/azure/sdk from https://github.com/mirror/sdk.
+//
+// The vcs option sets the version control system for a single couple,
+// overriding the -vcs flag. A line of the form
+//
+//	@default-vcs example.com hg
+//
+// sets the default for all couples whose import path is on the given host,
+// so that the version control system of a couple is taken from its own
+// vcs option, then the @default-vcs for its host, then its repo URL,
+// then the -vcs flag.
+//
+// The -append-git-suffix option appends .git to the repo URL in the go-import
+// tag of each git couple whose repo URL does not already end in .git, for git
+// hosts that require it. For wildcard couples, it is appended after the
+// wildcards are substituted. Documentation links are not affected.
+//
+// A repo URL indicates its version control system when it ends in .git,
+// .hg, .svn, .bzr, or .fossil, as the go command's own import paths can;
+// when its scheme is git, svn, or bzr, or one of these followed by +ssh;
+// when its host is github.com, gitlab.com, bitbucket.org, codeberg.org,
+// or git.sr.ht, which serve git, or hg.sr.ht, which serves hg; or when the
+// first label of its host is git, hg, or svn, as in hg.example.com.
+//
+// The enabled option switches a couple off, so that its import paths are
+// not found, without removing it from the config file. This is useful for
+// quickly taking a redirect out of service:
+//
+//	rsc.io/x86 https://github.com/rsc/x86 enabled=false
+//
+// The maintenance option marks a couple as temporarily unavailable, as while
+// its repo is being migrated. Requests for its import paths are answered with
+// 503 Service Unavailable and Retry-After: 3600 rather than with a go-import
+// tag that may be stale, while other couples are served as usual.
+// Like any option, it takes effect or is lifted when the config is reread:
+//
+//	rsc.io/x86 https://github.com/rsc/x86 maintenance=true
+//
+// The file path may instead name a directory, in which case every file in it
+// whose name ends in .txt, .json, or .toml is read, in lexical order.
+// It may also be an http or https URL, such as that of a central config
+// service, which is fetched at startup and on each reload. The config it serves
+// is read as JSON if the URL path ends in .json, the response is served
+// as application/json, or it begins with {, and as TOML if the URL path ends
+// in .toml or the response is served as application/toml. A fetch that
+// fails, times out after 30 seconds, or gets a status other than 200 OK
+// is reported as an error.
+//
+// An import path configured more than once, in the same file or in different
+// files of a directory, is reported as an error. The -on-duplicate option
+// changes this: with -on-duplicate=warn the last couple for the import path
+// is used after logging a warning, and with -on-duplicate=last-wins it is
+// used silently. Import paths are compared without any trailing slash,
+// so rsc.io/x and rsc.io/x/ are the same.
+//
+// Sending go-import-redirector a SIGHUP signal makes it reread its config.
+// The new config is read in full, while requests are still served from the
+// previous one, and then replaces it at once. Requests arriving during the
+// replacement are answered with 503 Service Unavailable and Retry-After: 1.
+// If the new config cannot be loaded, the previous one stays in effect.
+// Options given as flags are not reread.
+//
+// On SIGINT or SIGTERM, go-import-redirector stops accepting connections,
+// gives requests in progress up to 5 seconds to finish, cancels any repo
+// checks of -verify-repos still running, stops the -admin-addr server,
+// and exits.
+//
+// The -drain-delay option delays this shutdown after SIGTERM by the given
+// duration, as in -drain-delay=10s, for deployments behind a load balancer.
+// During the delay, requests are served as usual, except that the ping
+// endpoint answers 503 Service Unavailable, so that a load balancer using
+// it as a health check stops sending requests before they would be refused.
+//
+// A config file whose name ends in .json, or whose content starts with {,
+// is instead read as JSON: an object holding defaults and a list of mappings.
+// Each mapping has the fields import and repo, and optionally vcs, subdir,
+// ref, depth, cacheControl, docsHost, noDocs, redirectStatus, enabled,
+// maintenance, and hosts, an object mapping elements to hosts,
+// corresponding to the options above, and goSource,
+// the home, directory, and file templates of a go-source meta tag
+// (see https://github.com/golang/gddo/wiki/Source-Code-Links).
+// Where a mapping leaves docsHost, noDocs, or redirectStatus out,
+// the corresponding flag applies.
+// The defaults may set vcs and defaultRedirect, which apply unless the
+// corresponding flags are given, and hostVCS, mapping hosts to their default
+// version control system like @default-vcs. Unlike flags, the defaults are
+// reread with the rest of the config. Fields other than these are
+// reported as errors, to catch misspellings. For example:
+//
+//	{
+//		"defaults": {"vcs": "git", "hostVCS": {"example.com": "hg"}},
+//		"mappings": [
+//			{"import": "rsc.io/*", "repo": "https://github.com/rsc/*"},
+//			{"import": "example.com/x", "repo": "https://github.com/example/x",
+//			 "docsHost": "pkg.go.dev", "redirectStatus": 301},
+//			{"import": "9fans.net/go", "repo": "https://github.com/9fans/go",
+//			 "goSource": "https://github.com/9fans/go https://github.com/9fans/go/tree/master{/dir} https://github.com/9fans/go/blob/master{/dir}/{file}#L{line}"}
+//		]
+//	}
+//
+// A config file whose name ends in .toml is read as TOML, holding the same
+// fields in a [defaults] table, with its hostVCS in a [defaults.hostVCS]
+// table, and a [[mapping]] table for each mapping, followed by
+// a [mapping.hosts] table for its hosts, if any:
+//
+//	[defaults]
+//	vcs = "git"
+//
+//	[defaults.hostVCS]
+//	"example.com" = "hg"
+//
+//	[[mapping]]
+//	import = "rsc.io/*"
+//	repo = "https://github.com/rsc/*"
+//
+//	[[mapping]]
+//	import = "example.com/x"
+//	repo = "https://github.com/example/x"
+//	docsHost = "pkg.go.dev"
+//	redirectStatus = 301
+//
+// The -cache-max-age option sets the max-age, in seconds, of the
+// Cache-Control header sent for all other couples (default 3600).
+// Setting it to 0 omits the header. Not found responses are always
+// sent with Cache-Control: no-store.
+//
+// The -trailing-slash option canonicalizes the paths of pages with
+// 301 Moved Permanently redirects: with add, rsc.io/x86 is redirected to
+// rsc.io/x86/, and with strip, the other way around. The default, ignore,
+// serves both alike. Requests from the go command, which carry go-get=1,
+// are never redirected, so that go get works whatever the mode.
+//
+// Each page that redirects to documentation is sent with a Link header
+// naming the documentation page as canonical, as in
+// Link: <https://godoc.org/rsc.io/x86/x86asm>; rel="canonical",
+// so that search engines index that page rather than the redirect.
+//
+// Each page is sent with the Content-Security-Policy header given by the
+// -csp option, by default default-src 'none'; style-src 'unsafe-inline',
+// which allows nothing beyond what the built-in page needs, so that anything
+// injected into a page could not load or run. A -template that loads images
+// or scripts needs a policy allowing them; -csp= sends no header at all.
+//
+// Each page is sent with an ETag computed from its contents, and a request
+// whose If-None-Match header names that ETag is answered with 304 Not Modified,
+// so that clients and caches can cheaply revalidate a page they already hold.
+// Rendered pages are also kept in memory, and rendered again only after
+// the configuration is reloaded or the documentation host changes.
+//
+// The wildcard may instead stand for the first label of the host.
+// For example, if invoked as:
+//
+//	go-import-redirector '*.example.com/go' 'https://github.com/*/go'
+//
+// then foo.example.com/go/util is served from https://github.com/foo/go.
+// A couple for an exact host, such as foo.example.com/go, takes precedence.
+// Wildcard hosts are not passed to Let's Encrypt, which cannot issue
+// certificates for them.
+//
+// Request paths with an element that is empty or begins with a dot,
+// such as rsc.io/../evil or rsc.io/.x86, are not valid import paths
+// and are not found, whatever couples are configured. Configured import
+// paths are checked when loaded too: one with a scheme, an empty element,
+// or a first element that is not a host name, such as https://rsc.io/x
+// or rsc.io//x86, is reported as an error.
+//
+// The -wildcard-dots option controls how a wildcard element containing dots,
+// such as x86.v2, is substituted into the repo path, for forges that do not
+// allow dots in repo names. The default, keep, substitutes it unchanged;
+// replace substitutes it with each dot replaced by -wildcard-dot-replacement
+// (default ``-''), serving rsc.io/x86.v2 from https://github.com/rsc/x86-v2;
+// and reject treats import paths with such elements as not found.
+// The element is always kept unchanged in the import path.
+//
+// The -docsite option specifies the host serving documentation pages
+// (default ``godoc.org''), for example pkg.go.dev.
+//
+// The -docs-target option chooses the documentation page that a request
+// is sent to. With the default, package, it is the page of the requested
+// package, so that rsc.io/x86/x86asm goes to godoc.org/rsc.io/x86/x86asm;
+// with module, it is the page of the matched import root, godoc.org/rsc.io/x86,
+// whether the root was configured exactly or through a wildcard.
+// The Suffix field of a -template is then empty.
+//
+// The -verify-repos option fetches the repo URL of each couple at startup,
+// logging a warning for each one that cannot be fetched, such as a typo or
+// a private repo. With -verify-repos=strict, go-import-redirector instead
+// refuses to start. Wildcard couples are not checked, since their repos
+// are only known once a request arrives.
+//
+// The -self-test option resolves an import path for each enabled couple at
+// startup, with any wildcards standing for the element selftest, and renders
+// its page as a request would. If resolving or rendering fails, or the page
+// has an empty go-import tag, go-import-redirector names the couple and
+// refuses to start, rather than fail later on live requests.
+//
+// The -no-docs-redirect option omits the redirect to the documentation,
+// serving pages holding only the go-import and go-source tags, for networks
+// from which no documentation site can be reached.
+//
+// The -admin-addr option starts a second HTTP server on the given address
+// for administrative requests. It is meant to be bound to a private address,
+// such as localhost:8081. Sending a PUT or POST request with a host name,
+// or an http or https URL as for docs-host, as the body to /docsite changes
+// the documentation host for all subsequent requests without reloading
+// the import couples:
+//
+//	curl -X PUT -d pkg.go.dev localhost:8081/docsite
+//
+// A GET request to /mappings lists the current import couples as JSON,
+// with the import path, repo, and VCS of each, and a POST request to /reload
+// rereads the config file, as SIGHUP does. A GET request to /metrics reports,
+// in the OpenMetrics text format, the number of requests served to the go
+// command, recognized by ?go-get=1 or its Go-http-client user agent,
+// to browsers, and to other clients. The -admin-token option
+// requires every admin request to carry the given token in an
+// Authorization: Bearer header; others get 401 Unauthorized.
+// Without -admin-token, the redirector refuses to start unless the
+// admin address is a loopback address, such as localhost:8081 or [::1]:8081.
+// The admin server has the same timeouts and header limit as the public ones.
+//
+// The -default-redirect option specifies a URL, such as a project home page,
+// to which requests for the bare root of a configured host (like rsc.io/)
+// are redirected when no import path is configured for the root itself.
+// Requests for hosts that are not configured at all are answered with
+// 421 Misdirected Request, unless -fallback is given, telling a misrouted
+// request apart from one for an unknown path on a configured host, which
+// gets 404 Not Found. Both are logged, differently, unless -quiet is given.
+//
+// The -index option names an HTML file, such as a project landing page,
+// served for the bare root of each configured host in place of the
+// -default-redirect or the documentation of a wildcard root. As with
+// -default-redirect, an import path configured for the root itself
+// is served instead, and other paths are matched as usual.
+//
+// The -redirect-status option sets the status code of the HTTP redirects
+// sent for a bare wildcard root to its documentation and to the
+// -default-redirect and -fallback URLs: 302 Found (the default)
+// or 301 Moved Permanently.
+// Browsers and search engines cache a 301 indefinitely unless told otherwise,
+// so a permanent redirect can outlive a later change of -docs-site
+// or of the config; use it only for destinations that will not move.
+//
+// The -fallback option specifies the URL of another redirector, such as
+// one being migrated away from, to which requests matching no import path
+// are sent instead of failing with 404 Not Found. By default the client is
+// redirected there with the request path and query preserved; with
+// -fallback-mode=proxy the request is instead forwarded to it, keeping the
+// original Host header, and its response relayed to the client.
+//
+// Go-import-redirector also answers a few internal endpoints, such as
+// <import>/.ping, which replies "pong" without redirecting and is useful for
+// debugging TLS certificates, and <import>/.status, which reports as JSON
+// the version of the binary, the number of configured couples, whether TLS
+// is in use, and the uptime, to check that a deployment is as expected.
+// An import path that needs one of these names for a package can be freed
+// with the -internal-host option, which serves the internal endpoints only
+// on the given host (for example, -internal-host=admin.example.com serves
+// admin.example.com/.ping), passing the same paths on every other host
+// to the redirector.
+//
+// The -ping-path option moves the ping endpoint from .ping to another path
+// below each import root, as in -ping-path=.health for rsc.io/.health,
+// and the -no-ping option stops serving it at all.
+//
+// The -template option names a file holding an html/template to render
+// redirect pages in place of the built-in one, for example to add branding.
+// It is executed with the fields ImportRoot, VCS, VCSRoot, Subdir, GoSource,
+// Suffix, DocsHost, DocsURL, NoDocs, and Nested, the list of further go-import
+// tags described above, each with the fields ImportRoot, VCS, VCSRoot,
+// and Subdir. The go-import meta tag must be written as
+//
+//	<meta name="go-import" content="{{.ImportRoot}} {{.VCS}} {{.VCSRoot}}">
+//
+// for go get to work. A template that fails to parse or refers to other
+// fields is rejected at startup.
+//
+// The -debug-headers option adds X-Go-Import-Root, X-Go-Vcs, and X-Go-Repo
+// headers to each redirect page, reporting what the request resolved to,
+// for diagnosing a mapping without reading the page itself.
+// They are off by default so as not to expose configuration details.
+//
+// The -proxy-hints option answers module proxy requests, as sent by a go
+// command whose GOPROXY names the redirector. With GOPROXY=https://rsc.io,
+// these are for URLs such as https://rsc.io/rsc.io/x86/@v/list,
+// https://rsc.io/rsc.io/x86/@v/v1.0.0.mod, or https://rsc.io/rsc.io/x86/@latest,
+// whose path names the module whatever the host. Rather than being served
+// the page of a package, they are redirected to the same path on
+// the -proxy-upstream module proxy (default https://proxy.golang.org),
+// or fail with 404 Not Found if -proxy-upstream is empty or the module
+// is not configured. Without -proxy-hints such paths are treated like any other.
+//
+// The -gzip option compresses responses with gzip for clients whose
+// Accept-Encoding header allows it, such as browsers fetching a page
+// rendered with a large -template. Responses under 1 kB, including the
+// standard pages served to the go command, are sent uncompressed, since
+// compression would gain nothing. Compressed pages carry a weak ETag.
+//
+// The -quiet option stops the logging of problems with individual requests,
+// such as failed TLS handshakes, template errors, and errors reaching the
+// -fallback server in proxy mode, which can be noisy on a busy server.
+// Messages about starting, reloading, and fatal errors are still logged.
+//
+// The -rate-limit option limits the rate of requests from each client,
+// identified by its IP address, as in -rate-limit=10/s, allowing short bursts
+// of that many requests. Requests beyond the limit are answered with
+// 429 Too Many Requests and a Retry-After header. The internal endpoints
+// described below are not limited. By default there is no limit.
+//
+// Redirect pages are served only for GET and HEAD requests;
+// other methods get 405 Method Not Allowed.
+//
+// Every host also serves /robots.txt, which by default asks crawlers
+// to stay away from the redirect pages entirely. The -robots option
+// names a file to serve in its place.
+//
+// Likewise, every host answers requests for /favicon.ico, which browsers
+// make on their own, with 204 No Content rather than a 404 that would
+// clutter logs. The -favicon option names an icon file to serve instead.
+//
+// The -debug-allow-cidr option restricts the internal endpoints to clients
+// whose address is within the given network, such as 10.0.0.0/8; other
+// clients get 403 Forbidden. It may be repeated to allow several networks.
+// With the -trust-proxy option, the client address is taken from the last
+// entry of the X-Forwarded-For header added by a reverse proxy, rather than
+// from the connection. Likewise the host matched against the import paths is
+// taken from X-Forwarded-Host, when present. Pages and redirects never name
+// the redirector's own scheme, so X-Forwarded-Proto is not needed.
+// Only use -trust-proxy behind a proxy that sets these headers,
+// since clients connecting directly can send anything; without it they are ignored.
+//
+// The -block-user-agent option answers requests whose User-Agent header
+// matches the given regular expression with 403 Forbidden, before they are
+// matched against the import paths, to turn away abusive crawlers. It may be
+// repeated to block several user agents. A pattern that matches the user
+// agent of the go command, such as Go-http-client/1.1, is reported with a
+// warning at startup, since it would break ``go get''.
+//
+// The -max-entries option, also spelled -max-mappings, limits the number
+// of import couples loaded from the command line or a config file,
+// counting both wildcard and other couples (default 0, meaning no limit).
+// The number loaded is logged at startup and on each reload.
+// A config exceeding the limit is rejected at startup, unless -truncate-entries
+// is given, in which case the extra couples are dropped with a warning.
+//
+// The -max-line-bytes option limits the length of a line of a config file
+// (default 1MB); a file with a longer line is reported as malformed.
+//
+// The -resolve option prints what would be served for the given import path,
+// using the loaded couples, and exits without serving. For example:
+//
+//	$ go-import-redirector -resolve rsc.io/x86/x86asm rsc.io/* https://github.com/rsc/*
+//	go-import: rsc.io/x86 git https://github.com/rsc/x86
+//	docs: https://godoc.org/rsc.io/x86/x86asm
+//
+// The same is reported, for scripts and monitoring, by the internal endpoint
+// <import>/.resolve for the import path given as its path parameter, as in
+// rsc.io/.resolve?path=rsc.io/x86/x86asm. With format=json, it is reported
+// as a JSON object with the fields importRoot, vcs, repo, and docs, or
+// redirect. An import path that matches nothing gets 404 Not Found.
+//
+// The internal endpoint <import>/.reverse answers the opposite question,
+// for tooling that has a clone URL: it reports the import root served with
+// the repo URL given as its repo parameter, as in
+// rsc.io/.reverse?repo=https://github.com/rsc/x86.git, which reports rsc.io/x86
+// for the couple rsc.io/* https://github.com/rsc/*. Repo URLs are compared
+// without any ref, trailing slash, or .git suffix. A repo that no couple
+// serves gets 404 Not Found.
+//
+// The -version option prints the version, commit, and build date
+// of the binary and exits.
+//
 // Deployment on Google Cloud Platform
 //
 // For the case of a redirector for an entire domain (such as rsc.io above),
@@ -60,36 +621,108 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"html/template"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/noaleibo1/go-import-redirector/redirector"
 	"rsc.io/letsencrypt"
 )
 
 var (
 	addr             = flag.String("addr", ":http", "serve http on `address`")
-	serveTLS         = flag.Bool("tls", false, "serve https on :443")
+	serveTLS         = flag.Bool("tls", false, "serve https on -tls-addr")
+	rateLimit        = flag.String("rate-limit", "", "limit each client to `rate` requests, such as 10/s, 600/m, or 1000/h")
+	systemd          = flag.Bool("systemd", false, "serve on the socket passed by systemd socket activation, if any")
+	redirectStatus   = flag.Int("redirect-status", http.StatusFound, "redirect browsers with HTTP `status` 301 or 302")
+	onDuplicate      = flag.String("on-duplicate", "error", "handle an import path configured twice by `action`: error, warn, or last-wins")
+	network          = flag.String("net", "tcp", "listen on TCP addresses with `network` tcp, tcp4, or tcp6")
+	tlsAddr          = flag.String("tls-addr", ":https", "with -tls, serve https on `address`")
 	vcs              = flag.String("vcs", "git", "set version control `system`")
+	acme             = flag.String("acme", "letsencrypt", "with -tls, obtain certificates with ACME `client` letsencrypt or autocert")
+	acmeCacheDir     = flag.String("acme-cache-dir", "autocert-cache", "with -acme=autocert, cache certificates in `dir`")
+	acmeEmail        = flag.String("acme-email", "", "with -acme=autocert, register with the certificate authority as `email`")
 	letsEncryptEmail = flag.String("letsencrypt", "", "use lets encrypt to issue TLS certificate, agreeing to TOS as `email` (implies -tls)")
+	wildcardDots     = flag.String("wildcard-dots", "keep", "handle dots in wildcard elements by `action`: keep, replace, or reject")
+	dotReplacement   = flag.String("wildcard-dot-replacement", "-", "with -wildcard-dots=replace, substitute `string` for dots in the repo path")
+	docsTarget       = flag.String("docs-target", "package", "link to the documentation of the requested `target`: package or module")
+	appendGitSuffix  = flag.Bool("append-git-suffix", false, "append .git to git repo URLs lacking it in go-import tags")
+	readTimeout      = flag.Duration("read-timeout", 5*time.Second, "limit reading a request to `duration`")
+	writeTimeout     = flag.Duration("write-timeout", 10*time.Second, "limit writing a response to `duration`")
+	idleTimeout      = flag.Duration("idle-timeout", 120*time.Second, "close idle keep-alive connections after `duration`")
+	maxHeaderBytes   = flag.Int("max-header-bytes", 16<<10, "reject requests with headers larger than `n` bytes")
+	maxBodyBytes     = flag.Int64("max-body-bytes", 1<<10, "reject requests with bodies larger than `n` bytes")
+	maxEntries       = flag.Int("max-entries", 0, "load at most `n` import couples (0 means no limit)")
+	maxLineBytes     = flag.Int("max-line-bytes", 1<<20, "reject config files with lines longer than `n` bytes")
+	truncateEntries  = flag.Bool("truncate-entries", false, "drop couples beyond -max-entries with a warning instead of failing")
+	cacheMaxAge      = flag.Int("cache-max-age", 3600, "allow caching of redirect pages for `seconds`")
+	docsSite         = flag.String("docsite", "godoc.org", "redirect to documentation served by `host`")
+	adminAddr        = flag.String("admin-addr", "", "serve the admin API on `address` (disabled if empty)")
+	adminToken       = flag.String("admin-token", "", "require admin API requests to carry bearer `token`")
+	defaultRedirect  = flag.String("default-redirect", "", "redirect the bare root of configured hosts to `URL`")
+	internalHost     = flag.String("internal-host", "", "serve internal endpoints such as .ping only on `host`")
+	pingPath         = flag.String("ping-path", ".ping", "answer the ping endpoint at <import>/`path`")
+	noPing           = flag.Bool("no-ping", false, "do not serve the ping endpoint")
+	resolvePath      = flag.String("resolve", "", "print what is served for `import` path and exit, without serving")
+	printVersion     = flag.Bool("version", false, "print the version and exit")
+	trustProxy       = flag.Bool("trust-proxy", false, "trust X-Forwarded-For and X-Forwarded-Host headers from a reverse proxy")
+	fallback         = flag.String("fallback", "", "send requests matching no import path to the server at `URL` instead of failing with 404")
+	accessLogPath    = flag.String("access-log", "", "log requests in Combined Log Format to `file`, or - for standard output")
+	noDocsRedirect   = flag.Bool("no-docs-redirect", false, "serve only the go-import tag, without redirecting to documentation")
+	debugHeaders     = flag.Bool("debug-headers", false, "report the resolved import root, vcs, and repo in X-Go-* response headers")
+	templateFile     = flag.String("template", "", "render redirect pages with the html/template in `file`")
+	robotsFile       = flag.String("robots", "", "serve /robots.txt from `file` instead of disallowing all crawling")
+	indexFile        = flag.String("index", "", "serve the HTML `file` at the bare root of each configured host")
+	faviconFile      = flag.String("favicon", "", "serve /favicon.ico from `file` instead of answering 204 No Content")
+	fallbackMode     = flag.String("fallback-mode", "redirect", "send requests to -fallback by `mode`: redirect or proxy")
+	proxyHints       = flag.Bool("proxy-hints", false, "answer module proxy requests such as /<module>/@v/list by redirecting to -proxy-upstream")
+	proxyUpstream    = flag.String("proxy-upstream", "https://proxy.golang.org", "with -proxy-hints, redirect module proxy requests to the proxy at `URL`, or fail with 404 if empty")
+	drainDelay       = flag.Duration("drain-delay", 0, "on SIGTERM, fail the ping endpoint but keep serving for `duration` before shutting down")
+	basePath         = flag.String("base-path", "", "serve import paths below URL `path`, such as /vanity, stripping it before matching")
+	trailingSlash    = flag.String("trailing-slash", "ignore", "redirect browsers to import paths with a trailing slash added or stripped, by `mode`: add, strip, or ignore")
+	csp              = flag.String("csp", "default-src 'none'; style-src 'unsafe-inline'", "send pages with Content-Security-Policy `policy` (none if empty)")
+	runSelfTest      = flag.Bool("self-test", false, "at startup, render the page of each couple and exit if any fails")
+	gzipPages        = flag.Bool("gzip", false, "compress responses of 1 kB or more for clients accepting gzip")
+	quiet            = flag.Bool("quiet", false, "do not log problems with individual requests")
 	wildcard         bool
+	debugAllow       cidrList
+	blockedAgents    regexpList
+	verifyRepoMode   verifyMode
 )
 
+// requestLog logs problems with individual requests,
+// unlike the standard logger, which is kept for startup and fatal messages.
+var requestLog = log.New(os.Stderr, "go-import-redirector: ", log.LstdFlags)
+
+// quietRequestLog discards the output of requestLog if -quiet is given.
+func quietRequestLog() {
+	if *quiet {
+		requestLog.SetOutput(ioutil.Discard)
+	}
+}
+
 var (
-	filePath                     string
-	importCouplesWithoutWildCard map[string]string
-	importCouplesWithWildCard    map[string]string
+	filePath string
+	mappings = redirector.NewMappings()
 )
 
 func usage() {
 	fmt.Fprintf(os.Stderr, "usage: go-import-redirector <import> <repo>\n")
 	fmt.Fprintf(os.Stderr, "usage (read from file): go-import-redirector <file path>\n")
+	fmt.Fprintf(os.Stderr, "usage (read from $%s): go-import-redirector\n", mappingsEnv)
 	fmt.Fprintf(os.Stderr, "options:\n")
 	flag.PrintDefaults()
 	fmt.Fprintf(os.Stderr, "examples:\n")
@@ -105,50 +738,203 @@ func main() {
 	// log.SetFlags(0)
 	log.SetPrefix("go-import-redirector: ")
 	flag.Usage = usage
+	flag.Var(&debugAllow, "debug-allow-cidr", "allow debugging endpoints only from `network`, such as 10.0.0.0/8 (repeatable)")
+	flag.Var(&blockedAgents, "block-user-agent", "answer requests whose User-Agent matches `regexp` with 403 Forbidden (repeatable)")
+	flag.IntVar(maxEntries, "max-mappings", 0, "same as -max-entries")
+	flag.Var(&verifyRepoMode, "verify-repos", "at startup, warn about repos that cannot be fetched; =strict to fail instead")
 	flag.Parse()
-	if flag.NArg() == 0 || flag.NArg() > 2 {
+	if err := setFlagsFromEnv(flag.CommandLine); err != nil {
+		log.Fatal(err)
+	}
+	recordFlagsGiven()
+	quietRequestLog()
+	if *printVersion {
+		writeVersion(os.Stdout)
+		return
+	}
+	if flag.NArg() == 0 && os.Getenv(mappingsEnv) == "" || flag.NArg() > 2 {
 		flag.Usage()
 	}
 
-	hosts := []string{}
-	importCouplesWithWildCard = map[string]string{}
-	importCouplesWithoutWildCard = map[string]string{}
+	if err := checkNetwork(); err != nil {
+		log.Fatal(err)
+	}
+	warnBlockedGo()
+
+	if *rateLimit != "" {
+		l, err := newRateLimiter(*rateLimit)
+		if err != nil {
+			log.Fatal(err)
+		}
+		limiter = l
+	}
+
+	if !validRedirectStatus(*redirectStatus) {
+		log.Fatalf("invalid -redirect-status %d: must be 301 or 302", *redirectStatus)
+	}
+
+	if *adminAddr != "" {
+		if err := checkAdminAddr(*adminAddr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *maxLineBytes <= 0 {
+		log.Fatalf("invalid -max-line-bytes %d: must be positive", *maxLineBytes)
+	}
+
+	switch *acme {
+	case "letsencrypt", "autocert":
+	default:
+		log.Fatalf("invalid -acme %q: must be letsencrypt or autocert", *acme)
+	}
+
+	switch *onDuplicate {
+	case "error", "warn", "last-wins":
+	default:
+		log.Fatalf("invalid -on-duplicate %q: must be error, warn, or last-wins", *onDuplicate)
+	}
+
+	switch *wildcardDots {
+	case "keep", "replace", "reject":
+	default:
+		log.Fatalf("invalid -wildcard-dots %q: must be keep, replace, or reject", *wildcardDots)
+	}
+
+	*pingPath = strings.Trim(*pingPath, "/")
+	if *pingPath == "" && !*noPing {
+		log.Fatalf("invalid -ping-path: must not be empty (use -no-ping to turn the endpoint off)")
+	}
+
+	if *basePath != "" {
+		*basePath = "/" + strings.Trim(*basePath, "/")
+		if *basePath == "/" {
+			*basePath = ""
+		}
+	}
+
+	switch *trailingSlash {
+	case "add", "strip", "ignore":
+	default:
+		log.Fatalf("invalid -trailing-slash %q: must be add, strip, or ignore", *trailingSlash)
+	}
+
+	switch *docsTarget {
+	case "package", "module":
+	default:
+		log.Fatalf("invalid -docs-target %q: must be package or module", *docsTarget)
+	}
+
+	mappings.SetDocsHost(*docsSite)
+	mappings.SetDefaultVCS(*vcs)
+	mappings.SetDefaultRedirect(*defaultRedirect)
+	mappings.DocsTarget = *docsTarget
+	mappings.AppendGitSuffix = *appendGitSuffix
+	mappings.WildcardDots = *wildcardDots
+	mappings.DotReplacement = *dotReplacement
+
+	if *templateFile != "" {
+		t, err := loadTemplate(*templateFile)
+		if err != nil {
+			log.Fatalf("loading -template: %v", err)
+		}
+		tmpl = t
+	}
 
 	// Read imports and repos from file
-	if flag.NArg() == 1 {
+	switch flag.NArg() {
+	case 0:
+		if err := readMappingsEnv(mappings); err != nil {
+			log.Fatal(err)
+		}
+	case 1:
 		filePath = flag.Arg(0)
-		if err := readFile(); err != nil {
+		if err := readFile(mappings); err != nil {
 			log.Fatal(err)
 		}
-	} else {
-		importPath := strings.TrimSuffix(flag.Arg(0), "/") + "/"
-		repoPath := strings.TrimSuffix(flag.Arg(1), "/") + "/"
-		importCouplesWithoutWildCard[importPath] = repoPath
+	default:
+		if _, err := checkEntryLimit(mappings); err != nil {
+			log.Fatal(err)
+		}
+		importPath, c := makeCouple(flag.Arg(0), expandRepo(flag.Arg(1)))
+		mappings.Add(importPath, c)
 	}
+	warnShadowed()
+	logMappingCount()
 
-	for importPath, repoPath := range importCouplesWithoutWildCard {
-		if err := validateInput(importPath, repoPath); err != nil {
+	if err := readStaticFiles(); err != nil {
+		log.Fatal(err)
+	}
+	if *fallback != "" {
+		h, err := newFallback(*fallback, *fallbackMode)
+		if err != nil {
 			log.Fatal(err)
 		}
-		if strings.HasSuffix(importPath, "/*") {
-			delete(importCouplesWithoutWildCard, importPath)
-			importPath = strings.TrimSuffix(importPath, "/*")
-			repoPath = strings.TrimSuffix(repoPath, "/*")
-			importCouplesWithWildCard[importPath+"/"] = repoPath + "/"
+		fallbackHandler = h
+	}
+
+	hosts := registerHandlers(http.DefaultServeMux)
+
+	if *resolvePath != "" {
+		if err := printResolution(os.Stdout, *resolvePath); err != nil {
+			log.Fatal(err)
 		}
+		return
+	}
 
-		http.HandleFunc(importPath, redirect)
-		http.HandleFunc(importPath+"/.ping", pong) // non-redirecting URL for debugging TLS certificates
+	// Background work stops once the process is told to stop,
+	// and main waits for it before exiting.
+	var background sync.WaitGroup
+	defer background.Wait()
+	ctx, stop := stopContext()
+	defer stop()
 
-		host := importPath
-		if i := strings.Index(host, "/"); i >= 0 {
-			host = host[:i]
+	if *runSelfTest {
+		if err := selfTest(); err != nil {
+			log.Fatal(err)
 		}
-		hosts = append(hosts, host)
+	}
+
+	checkRepos(ctx)
+
+	if *accessLogPath != "" {
+		if err := openAccessLog(*accessLogPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if filePath != "" {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			reloadOnHangup(ctx)
+		}()
+	}
+
+	if *adminAddr != "" {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			serveAdmin(ctx, *adminAddr)
+		}()
 	}
 
 	if !*serveTLS {
-		log.Fatal(http.ListenAndServe(*addr, nil))
+		l, err := serverListener(*addr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := serve(ctx, l); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *acme == "autocert" {
+		if err := serveAutocert(ctx, newAutocertManager(hosts), *addr, *tlsAddr); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
 	m := new(letsencrypt.Manager)
@@ -161,112 +947,752 @@ func main() {
 		}
 	}
 
-	log.Fatal(m.Serve())
+	if err := serveLetsEncrypt(ctx, m, *addr, *tlsAddr); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// registerHandlers registers on mux the handlers for the loaded couples
+// and their internal endpoints, and returns the hosts served, as needed
+// for their certificates.
+func registerHandlers(mux *http.ServeMux) []string {
+	hosts := []string{}
+	if err := validateCouples(mappings); err != nil {
+		log.Fatal(err)
+	}
+
+	registered := map[string]bool{}
+	for importPath := range mappings.All() {
+		// Wildcard couples are kept under their full template,
+		// but served by the handler for the path up to the wildcard.
+		// A wildcard host can only be served by the catch-all handler.
+		pattern := importPath
+		if strings.HasPrefix(importPath, "*.") {
+			if !registered["/"] {
+				registered["/"] = true
+				mux.HandleFunc("/", redirect)
+			}
+			continue
+		}
+		if i := strings.Index(importPath, "/*/"); i >= 0 {
+			pattern = importPath[:i+1]
+		} else if strings.HasSuffix(importPath, "/**/") {
+			pattern = strings.TrimSuffix(importPath, "**/")
+		}
+
+		if !registered[pattern] {
+			registered[pattern] = true
+			mux.HandleFunc(pattern, redirect)
+			// Serve rsc.io/x86 the same as rsc.io/x86/, rather than
+			// letting the mux redirect it there.
+			if p := strings.TrimSuffix(pattern, "/"); strings.Contains(p, "/") {
+				mux.HandleFunc(p, redirect)
+			}
+			if *internalHost == "" {
+				handleInternal(mux, strings.TrimSuffix(pattern, "/"))
+			}
+		}
+
+		host := importPath
+		if i := strings.Index(host, "/"); i >= 0 {
+			host = host[:i]
+		}
+		hosts = append(hosts, host)
+	}
+
+	// A config file can be reloaded, possibly adding import paths
+	// not covered by the patterns above.
+	if filePath != "" && !registered["/"] {
+		registered["/"] = true
+		mux.HandleFunc("/", redirect)
+	}
+
+	// The bare root of each configured host is handled too,
+	// so that it can be sent to the -default-redirect landing page
+	// or served the -index page.
+	if *indexFile != "" {
+		b, err := ioutil.ReadFile(*indexFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		indexPage = b
+	}
+	if mappings.DefaultRedirect() != "" || indexPage != nil {
+		for _, host := range hosts {
+			if !registered[host+"/"] {
+				registered[host+"/"] = true
+				mux.HandleFunc(host+"/", redirect)
+			}
+		}
+	}
+
+	if *internalHost != "" {
+		handleInternal(mux, *internalHost)
+		hosts = append(hosts, *internalHost)
+	}
+
+	// Host-specific patterns such as rsc.io/ take precedence over /robots.txt,
+	// so it is registered for each host as well as for any other host.
+	for _, host := range append(hosts, "") {
+		if !registered[host+"/robots.txt"] {
+			registered[host+"/robots.txt"] = true
+			mux.HandleFunc(host+"/robots.txt", robots)
+		}
+	}
+	for _, host := range append(hosts, "") {
+		if !registered[host+"/favicon.ico"] {
+			registered[host+"/favicon.ico"] = true
+			mux.HandleFunc(host+"/favicon.ico", favicon)
+		}
+	}
+
+	// With a fallback, every request not served by a couple goes to it,
+	// whatever its host.
+	if *fallback != "" {
+		if !registered["/"] {
+			registered["/"] = true
+			mux.HandleFunc("/", redirect)
+		}
+	}
+	return hosts
+}
+
+// logMappingCount logs the number of couples loaded.
+func logMappingCount() {
+	n, wild := 0, 0
+	for importPath := range mappings.All() {
+		n++
+		if redirector.IsWildcard(importPath) {
+			wild++
+		}
+	}
+	log.Printf("Loaded %d import couples (%d with wildcards)", n, wild)
+}
+
+// warnShadowed logs a warning for each couple without a wildcard
+// whose import path a wildcard couple would otherwise serve.
+func warnShadowed() {
+	shadowed := mappings.Shadowed()
+	var paths []string
+	for importPath := range shadowed {
+		paths = append(paths, importPath)
+	}
+	sort.Strings(paths)
+	for _, importPath := range paths {
+		log.Printf("warning: %s is also matched by %s; the exact entry takes precedence", strings.TrimSuffix(importPath, "/"), strings.TrimSuffix(shadowed[importPath], "/"))
+	}
+}
+
+// validateCouples checks every configured couple with validateInput.
+func validateCouples(m *redirector.Mappings) error {
+	for importPath, c := range m.All() {
+		// The wildcards of the import path are substituted into
+		// the repo and then into the subdir, so they are checked as one.
+		repo := c.Repo
+		if len(repoWildcardElems(c.Subdir)) > 0 {
+			repo += "/" + c.Subdir
+		}
+		if (len(c.Hosts) > 0) != strings.Contains(c.Repo, redirector.HostPlaceholder) {
+			return fmt.Errorf("hosts must be given exactly when the repo has %s: %s %s", redirector.HostPlaceholder, strings.TrimSuffix(importPath, "/"), c.Repo)
+		}
+		if err := validateInput(importPath, repo); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func validateInput(importPath string, repoPath string) error {
+	if err := checkImportPath(importPath); err != nil {
+		return err
+	}
 	if !strings.Contains(repoPath, "://") {
-		log.Fatal("repo path must be full URL")
-		return fmt.Errorf("repo path must be full URL")
+		return fmt.Errorf("repo path must be full URL: %s", repoPath)
+	}
+	wildcards := wildcardElems(strings.Split(strings.TrimSuffix(importPath, "/"), "/"))
+	if strings.HasPrefix(importPath, "*.") {
+		wildcards = append([]string{"*"}, wildcards...)
+	}
+	repoWildcards := repoWildcardElems(repoPath)
+	if strings.Contains(importPath, "/**/") != (strings.Contains(repoPath, "/**/") || strings.HasSuffix(repoPath, "/**")) {
+		return fmt.Errorf("either both import and repo must have /** or neither: %s %s", importPath, repoPath)
+	}
+	if strings.Contains(importPath, "/**/") && !strings.HasSuffix(importPath, "/**/") {
+		return fmt.Errorf("/** must be the last element of the import path: %s", importPath)
 	}
-	if strings.HasSuffix(importPath, "/*") != strings.HasSuffix(importPath, "/*") {
-		log.Fatal("either both import and repo must have /* or neither")
-		return fmt.Errorf("either both import and repo must have /* or neither")
+	if (len(wildcards) > 0) != (len(repoWildcards) > 0) {
+		return fmt.Errorf("either both import and repo must have /* (or %s in repo) or neither: %s %s", redirector.Placeholder, importPath, repoPath)
+	}
+	if strings.Join(wildcards, "/") != strings.Join(repoWildcards, "/") {
+		return fmt.Errorf("import and repo must have the same wildcards in the same order: %s %s", importPath, repoPath)
 	}
 	return nil
 }
 
-func readFile() error {
-	log.Printf("Reading file: %s", filePath)
-	reader, err := os.Open(filePath)
+// tmpl renders redirect pages: the built-in redirector.PageTemplate,
+// or the template loaded from -template.
+var tmpl = redirector.PageTemplate
+
+// loadTemplate parses the page template in the named file and checks it by
+// rendering sample data with every field set, including a nested tag, both
+// with and without documentation, so that references to unknown fields in
+// any branch are reported at startup rather than when serving.
+func loadTemplate(name string) (*template.Template, error) {
+	t, err := template.ParseFiles(name)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	for _, noDocs := range []bool{false, true} {
+		sample := &redirector.Page{
+			GoImport: redirector.GoImport{
+				ImportRoot: "example.com/pkg",
+				VCS:        "git",
+				VCSRoot:    "https://example.com/repo",
+				Subdir:     "sub",
+			},
+			GoSource:   "https://example.com/repo https://example.com/repo/tree{/dir} https://example.com/repo/blob{/dir}/{file}#L{line}",
+			Suffix:     "/sub",
+			DocsHost:   "godoc.org",
+			DocsURL:    "https://godoc.org/example.com/pkg/sub",
+			NoDocs:     noDocs,
+			Nested: []redirector.GoImport{
+				{ImportRoot: "example.com/pkg/nested", VCS: "git", VCSRoot: "https://example.com/repo", Subdir: "nested"},
+			},
+		}
+		if err := t.Execute(ioutil.Discard, sample); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func redirect(w http.ResponseWriter, req *http.Request) {
+	if h := internalEndpoint(req); h != nil {
+		h(w, req)
+		return
+	}
+	if req.Method != "GET" && req.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if rateLimited(w, req) || duringReload(w) || proxyHint(w, req) || misdirected(w, req) {
+		return
+	}
+	path := strings.TrimRight(req.Host+req.URL.Path, "/") + "/"
+	if req.URL.Path == "/" && indexPage != nil && mappings.Match(path) == nil {
+		serveIndex(w, req)
+		return
+	}
+	// Take the generation of the page cache first, so that a page
+	// resolved from couples being replaced by a reload is not cached.
+	gen := pages.generation()
+	d, c, redirectURL := resolve(path)
+	if redirectURL != "" {
+		status := *redirectStatus
+		if c != nil && c.RedirectStatus != 0 {
+			status = c.RedirectStatus
+		}
+		http.Redirect(w, req, redirectURL, status)
+		return
+	}
+	if d == nil {
+		notFound(w, req)
+		return
+	}
+	if c.Maintenance {
+		redirector.ServeMaintenance(w, d.ImportRoot)
+		return
+	}
+	if canonicalizeSlash(w, req) {
+		return
+	}
+	// Carry the query, such as ?tab=doc, over to the documentation.
+	// The go-get parameter is only meaningful here.
+	q := req.URL.Query()
+	q.Del("go-get")
+	if len(q) > 0 {
+		d.DocsURL += "?" + q.Encode()
+	}
+	page, err := renderPage(path+"?"+q.Encode(), gen, d)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if *csp != "" {
+		w.Header().Set("Content-Security-Policy", *csp)
+	}
+	if !d.NoDocs {
+		w.Header().Set("Link", "<"+d.DocsURL+`>; rel="canonical"`)
+	}
+	if *debugHeaders {
+		w.Header().Set("X-Go-Import-Root", d.ImportRoot)
+		w.Header().Set("X-Go-Vcs", d.VCS)
+		w.Header().Set("X-Go-Repo", d.VCSRoot)
+	}
+	var body []byte
+	if err != nil {
+		// Keep ``go get'' working, but don't let caches keep the degraded page.
+		requestLog.Printf("executing template for %s: %v", req.Host+req.URL.Path, err)
+		var buf bytes.Buffer
+		redirector.WriteFallbackPage(&buf, d)
+		body = buf.Bytes()
+		w.Header().Set("Cache-Control", "no-store")
+	} else {
+		body = page.body
+		if cc := cacheControl(c); cc != "" {
+			w.Header().Set("Cache-Control", cc)
+		}
+		w.Header().Set("ETag", page.etag)
+		if etagMatch(req.Header.Get("If-None-Match"), page.etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	// A HEAD response carries the headers of the GET response, including its length.
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if req.Method == "HEAD" {
+		return
+	}
+	w.Write(body)
+}
+
+// canonicalizeSlash reports whether req, for a page to be served, should
+// be redirected as -trailing-slash says, and if so redirects it with
+// 301 Moved Permanently to its path with a trailing slash added or stripped.
+// Requests from the go command, marked go-get=1, are always served as they are.
+func canonicalizeSlash(w http.ResponseWriter, req *http.Request) bool {
+	if *trailingSlash == "ignore" || req.FormValue("go-get") == "1" {
+		return false
+	}
+	p := req.URL.Path
+	switch {
+	case *trailingSlash == "add" && !strings.HasSuffix(p, "/"):
+		p += "/"
+	case *trailingSlash == "strip" && strings.HasSuffix(p, "/") && strings.TrimRight(p, "/") != "":
+		p = strings.TrimRight(p, "/")
+	default:
+		return false
+	}
+	u := *req.URL
+	u.Scheme, u.Host, u.Path, u.RawPath = "", "", p, ""
+	http.Redirect(w, req, u.String(), http.StatusMovedPermanently)
+	return true
+}
+
+// etagMatch reports whether the If-None-Match header value list
+// names etag, comparing weakly as RFC 7232 requires.
+func etagMatch(list, etag string) bool {
+	for _, t := range strings.Split(list, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// misdirected reports whether req is for a host with no configured couples
+// and if so replies with 421 Misdirected Request, without matching its path.
+// With -fallback, such requests are left to the fallback server instead.
+func misdirected(w http.ResponseWriter, req *http.Request) bool {
+	if fallbackHandler != nil || mappings.HasHost(req.Host) {
+		return false
+	}
+	requestLog.Printf("misdirected request for %s: host not configured", req.Host+req.URL.Path)
+	http.Error(w, "misdirected request: host not served here", http.StatusMisdirectedRequest)
+	return true
+}
+
+// resolve matches path, the host and URL path of a request ending in a slash,
+// against the configured couples. It returns the page data for the matching
+// couple, or, if the request should instead be redirected elsewhere,
+// the URL to redirect to, with the couple it was derived from, if any.
+// If nothing matches, d is nil and redirectURL is empty.
+func resolve(path string) (d *redirector.Page, c *redirector.Couple, redirectURL string) {
+	m := mappings.Match(path)
+	if m == nil {
+		if strings.Index(path, "/") == len(path)-1 && mappings.DefaultRedirect() != "" {
+			return nil, nil, mappings.DefaultRedirect()
+		}
+		if docsURL, c := mappings.RootDocsURL(path); docsURL != "" && !*noDocsRedirect {
+			return nil, c, docsURL
+		}
+		return nil, nil, ""
+	}
+	d = m.Page()
+	d.NoDocs = d.NoDocs || *noDocsRedirect
+	return d, m.Couple, ""
+}
+
+// checkImportPath checks that importPath, normalized to end in a slash,
+// is a host name, optionally with a port or a wildcard first label,
+// followed by non-empty path elements.
+func checkImportPath(importPath string) error {
+	p := strings.TrimSuffix(importPath, "/")
+	if strings.Contains(p, "://") {
+		return fmt.Errorf("import path must not have a scheme: %s", p)
+	}
+	elems := strings.Split(p, "/")
+	for _, elem := range elems[1:] {
+		if elem == "" {
+			return fmt.Errorf("import path must not have empty elements: %s", p)
+		}
 	}
-	scanner := bufio.NewScanner(reader)
-	for scanner.Scan() {
-		fields := strings.Fields(scanner.Text())
+	host := elems[0]
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		if _, err := strconv.ParseUint(host[i+1:], 10, 16); err != nil {
+			return fmt.Errorf("import path has invalid port: %s", p)
+		}
+		host = host[:i]
+	}
+	for i, label := range strings.Split(host, ".") {
+		if i == 0 && label == "*" && host != "*" {
+			continue
+		}
+		if !validLabel(label) {
+			return fmt.Errorf("import path must begin with a host name: %s", p)
+		}
+	}
+	return nil
+}
 
-		switch len(fields) {
-		case 0:
+// validLabel reports whether label can be a label of a host name:
+// letters, digits, and hyphens, neither beginning nor ending with a hyphen.
+func validLabel(label string) bool {
+	if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, r := range label {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// repoWildcardElems returns the wildcards of the repo URL template repoPath
+// in order: its * and ** elements, and a * for each placeholder.
+// A host placeholder takes the first wildcard, wherever it appears.
+func repoWildcardElems(repoPath string) []string {
+	var w []string
+	if strings.Contains(repoPath, redirector.HostPlaceholder) {
+		w = append(w, "*")
+	}
+	for _, e := range strings.Split(repoPath, "/") {
+		if e == "*" || e == "**" {
+			w = append(w, e)
 			continue
-		case 2:
-			importPath := strings.TrimSuffix(fields[0], "/") + "/"
-			repoPath := strings.TrimSuffix(fields[1], "/") + "/"
-			importCouplesWithoutWildCard[importPath] = repoPath
-		default:
-			return fmt.Errorf("file malformed: %s", scanner.Text())
+		}
+		for n := strings.Count(e, redirector.Placeholder); n > 0; n-- {
+			w = append(w, "*")
+		}
+	}
+	return w
+}
+
+// wildcardElems returns the * and ** elements among elems, in order.
+func wildcardElems(elems []string) []string {
+	var w []string
+	for _, e := range elems {
+		if e == "*" || e == "**" {
+			w = append(w, e)
+		}
+	}
+	return w
+}
+
+// printResolution prints to w what is served for importPath,
+// as computed by resolve: the go-import tag and documentation URL,
+// or the URL the request is redirected to.
+func printResolution(w io.Writer, importPath string) error {
+	importPath = strings.TrimPrefix(strings.TrimPrefix(importPath, "https://"), "http://")
+	d, _, redirectURL := resolve(strings.TrimRight(importPath, "/") + "/")
+	switch {
+	case redirectURL != "":
+		fmt.Fprintf(w, "redirect: %s\n", redirectURL)
+	case d == nil:
+		return fmt.Errorf("%s: not found", importPath)
+	default:
+		fmt.Fprintf(w, "go-import: %s\n", d.GoImport)
+		for _, t := range d.Nested {
+			fmt.Fprintf(w, "go-import: %s\n", t)
+		}
+		if d.GoSource != "" {
+			fmt.Fprintf(w, "go-source: %s %s\n", d.ImportRoot, d.GoSource)
+		}
+		if !d.NoDocs {
+			fmt.Fprintf(w, "docs: %s\n", d.DocsURL)
 		}
 	}
 	return nil
 }
 
-var tmpl = template.Must(template.New("main").Parse(`<!DOCTYPE html>
-<html>
-<head>
-<meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
-<meta name="go-import" content="{{.ImportRoot}} {{.VCS}} {{.VCSRoot}}">
-<meta http-equiv="refresh" content="0; url=https://godoc.org/{{.ImportRoot}}{{.Suffix}}">
-</head>
-<body>
-Redirecting to docs at <a href="https://godoc.org/{{.ImportRoot}}{{.Suffix}}">godoc.org/{{.ImportRoot}}{{.Suffix}}</a>...
-</body>
-</html>
-`))
+// Cache lifetimes used when deriving Cache-Control from a repo ref.
+const (
+	tagMaxAge    = 24 * 60 * 60 // tags and commits are not expected to move
+	branchMaxAge = 5 * 60       // branches move, so keep this short
+)
 
-type data struct {
-	ImportRoot string
-	VCS        string
-	VCSRoot    string
-	Suffix     string
+// cacheControl returns the Cache-Control header to send for pages of c.
+// If the couple does not set one explicitly, it is derived from the ref
+// in the repo URL: refs naming a tag or commit are cached for a day,
+// refs naming a branch for five minutes. Pages of a repo without a ref
+// are cached for -cache-max-age seconds.
+func cacheControl(c *redirector.Couple) string {
+	if c.CacheControl != "" {
+		return c.CacheControl
+	}
+	ref := c.Ref
+	if ref == "" {
+		ref = repoRef(c.Repo)
+	}
+	switch {
+	case ref == "" && *cacheMaxAge <= 0:
+		return ""
+	case ref == "":
+		return fmt.Sprintf("public, max-age=%d", *cacheMaxAge)
+	case isImmutableRef(ref):
+		return fmt.Sprintf("public, max-age=%d", tagMaxAge)
+	default:
+		return fmt.Sprintf("public, max-age=%d", branchMaxAge)
+	}
 }
 
-func redirect(w http.ResponseWriter, req *http.Request) {
-	log.Print("In redirect")
-	path := strings.TrimSuffix(req.Host+req.URL.Path, "/") + "/"
-	var importRoot, repoRoot, suffix string
-	if repoPath, ok := importCouplesWithoutWildCard[path]; ok {
-		importRoot = path
-		repoRoot = repoPath
-		suffix = ""
-	} else if importPath, ok := getImportPathForWildCard(path); ok {
-		if path == importPath {
-			http.Redirect(w, req, "https://godoc.org/"+repoPath, 302)
-			return
+// repoRef returns the ref named in a repo URL, either as the URL fragment
+// (https://github.com/rsc/x86#v1.0.0) or as a ref query parameter
+// (https://example.com/x86?ref=master). It returns "" if there is none.
+func repoRef(repo string) string {
+	repo = strings.TrimSuffix(repo, "/")
+	if i := strings.Index(repo, "#"); i >= 0 {
+		return repo[i+1:]
+	}
+	if i := strings.Index(repo, "?"); i >= 0 {
+		for _, kv := range strings.Split(repo[i+1:], "&") {
+			if strings.HasPrefix(kv, "ref=") {
+				return kv[len("ref="):]
+			}
 		}
-		elem := path[len(importPath):]
-		if i := strings.Index(elem, "/"); i >= 0 {
-			elem, suffix = elem[:i], elem[i:]
+	}
+	return ""
+}
+
+// isImmutableRef reports whether ref looks like a tag or a commit hash
+// rather than a branch name.
+func isImmutableRef(ref string) bool {
+	if strings.HasPrefix(ref, "refs/tags/") || strings.HasPrefix(ref, "tags/") {
+		return true
+	}
+	if strings.HasPrefix(ref, "refs/heads/") {
+		return false
+	}
+	if len(ref) >= 7 && len(ref) <= 40 && strings.Trim(ref, "0123456789abcdef") == "" {
+		return true
+	}
+	// Semantic version tags: v1, v1.2, v1.2.3, v1.2.3-rc.1.
+	v := strings.TrimPrefix(ref, "v")
+	if v == "" || v[0] < '0' || v[0] > '9' {
+		return false
+	}
+	for _, r := range v {
+		if !('0' <= r && r <= '9' || r == '.' || r == '-' || r == '+' || 'a' <= r && r <= 'z') {
+			return false
 		}
-		importRoot = importPath + elem
-		repoRoot = repoPath + elem
-	} else {
-		http.NotFound(w, req)
+	}
+	return true
+}
+
+// notFound replies with a 404 that caches and CDNs must not keep,
+// so that a newly added couple takes effect immediately.
+// The host of req is configured, unlike those answered by misdirected,
+// so the path is likely a typo or a package not yet configured.
+func notFound(w http.ResponseWriter, req *http.Request) {
+	if fallbackHandler != nil {
+		fallbackHandler.ServeHTTP(w, req)
 		return
 	}
-	d := &data{
-		ImportRoot: strings.TrimSuffix(importRoot, "/"),
-		VCS:        *vcs,
-		VCSRoot:    repoRoot,
-		Suffix:     suffix,
+	path := strings.TrimSuffix(req.Host+req.URL.Path, "/")
+	requestLog.Printf("not found: %s: no import path configured", path)
+	w.Header().Set("Cache-Control", "no-store")
+	http.Error(w, "404 page not found: no import path configured for "+path, http.StatusNotFound)
+}
+
+// handleInternal registers on mux the internal endpoints under prefix,
+// such as rsc.io for rsc.io/.ping.
+func handleInternal(mux *http.ServeMux, prefix string) {
+	if !*noPing {
+		mux.HandleFunc(prefix+"/"+*pingPath, debugOnly(pong)) // non-redirecting URL for debugging TLS certificates
 	}
-	log.Printf("data:\n ImportRoot: %s, VCS: %s, VCSRoot: %s, Suffix: %s", d.ImportRoot, d.VCS, d.VCSRoot, d.Suffix)
-	var buf bytes.Buffer
-	err := tmpl.Execute(&buf, d)
-	if err != nil {
-		http.Error(w, err.Error(), 500)
+	mux.HandleFunc(prefix+"/.status", debugOnly(status))
+	mux.HandleFunc(prefix+"/.resolve", debugOnly(resolveEndpoint))
+	mux.HandleFunc(prefix+"/.reverse", debugOnly(reverseEndpoint))
+}
+
+// internalEndpoint returns the handler of the internal endpoint requested
+// by req, such as status for rsc.io/x86/.status, or nil. Import paths added
+// by a reload have no patterns of their own on the mux, so requests for
+// their endpoints reach redirect through the catch-all pattern instead.
+func internalEndpoint(req *http.Request) http.HandlerFunc {
+	if *internalHost != "" || !mappings.HasHost(req.Host) {
+		return nil
+	}
+	path := req.URL.Path
+	switch {
+	case !*noPing && strings.HasSuffix(path, "/"+*pingPath):
+		return debugOnly(pong)
+	case strings.HasSuffix(path, "/.status"):
+		return debugOnly(status)
+	case strings.HasSuffix(path, "/.resolve"):
+		return debugOnly(resolveEndpoint)
+	case strings.HasSuffix(path, "/.reverse"):
+		return debugOnly(reverseEndpoint)
+	}
+	return nil
+}
+
+func pong(w http.ResponseWriter, req *http.Request) {
+	if atomic.LoadInt32(&draining) != 0 {
+		w.Header().Set("Cache-Control", "no-store")
+		http.Error(w, "draining", http.StatusServiceUnavailable)
 		return
 	}
-	w.Write(buf.Bytes())
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "pong")
 }
-func getImportPathForWildCard(path string) (string, bool) {
-	for importPath, _ := range importCouplesWithoutWildCard {
-		if strings.HasPrefix(path, importPath) {
-			return importPath, true
+
+// readStaticFiles reads the files given by -robots
+// into the pages served for them.
+func readStaticFiles() error {
+	for _, f := range []struct {
+		name string
+		page *[]byte
+	}{
+		{*robotsFile, &robotsTxt},
+		{*faviconFile, &faviconICO},
+	} {
+		if f.name == "" {
+			continue
+		}
+		b, err := ioutil.ReadFile(f.name)
+		if err != nil {
+			return err
 		}
+		*f.page = b
 	}
-	return "", false
+	return nil
 }
 
-func pong(w http.ResponseWriter, req *http.Request) {
-	fmt.Fprintf(w, "pong")
+// robotsTxt is served as /robots.txt.
+var robotsTxt = []byte("User-agent: *\nDisallow: /\n")
+
+func robots(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(robotsTxt)
+}
+
+// indexPage, if not nil, is served for the bare root of each configured host.
+var indexPage []byte
+
+func serveIndex(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(indexPage)))
+	if req.Method == "HEAD" {
+		return
+	}
+	w.Write(indexPage)
+}
+
+// faviconICO, if not nil, is served as /favicon.ico.
+var faviconICO []byte
+
+func favicon(w http.ResponseWriter, req *http.Request) {
+	if faviconICO == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(faviconICO))
+	w.Write(faviconICO)
+}
+
+// resolveEndpoint reports what is served for the import path given
+// by the path parameter, as -resolve does, in plain text or, with
+// format=json, as JSON.
+func resolveEndpoint(w http.ResponseWriter, req *http.Request) {
+	importPath := req.FormValue("path")
+	if importPath == "" {
+		http.Error(w, "missing path parameter", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	if req.FormValue("format") != "json" {
+		var buf bytes.Buffer
+		if err := printResolution(&buf, importPath); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(buf.Bytes())
+		return
+	}
+	importPath = strings.TrimPrefix(strings.TrimPrefix(importPath, "https://"), "http://")
+	d, _, redirectURL := resolve(strings.TrimRight(importPath, "/") + "/")
+	if d == nil && redirectURL == "" {
+		http.Error(w, importPath+": not found", http.StatusNotFound)
+		return
+	}
+	var res struct {
+		ImportRoot string `json:"importRoot,omitempty"`
+		VCS        string `json:"vcs,omitempty"`
+		Repo       string `json:"repo,omitempty"`
+		Docs       string `json:"docs,omitempty"`
+		Redirect   string `json:"redirect,omitempty"`
+	}
+	if d != nil {
+		res.ImportRoot, res.VCS, res.Repo = d.ImportRoot, d.VCS, d.VCSRoot
+		if !d.NoDocs {
+			res.Docs = d.DocsURL
+		}
+	}
+	res.Redirect = redirectURL
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// reverseEndpoint reports the import root served with the repo URL
+// given by the repo parameter, in plain text.
+func reverseEndpoint(w http.ResponseWriter, req *http.Request) {
+	repo := req.FormValue("repo")
+	if repo == "" {
+		http.Error(w, "missing repo parameter", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	importPath, ok := mappings.Reverse(repo)
+	if !ok {
+		http.Error(w, repo+": no import path configured", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, importPath)
+}
+
+// startTime is when the server started, for reporting uptime.
+var startTime = time.Now()
+
+// status reports, as JSON, diagnostics useful for checking that a
+// deployment is running the expected build with the expected config.
+func status(w http.ResponseWriter, req *http.Request) {
+	st := struct {
+		Version  string `json:"version"`
+		Commit   string `json:"commit"`
+		Mappings int    `json:"mappings"`
+		TLS      bool   `json:"tls"`
+		Uptime   string `json:"uptime"`
+	}{
+		Version:  version,
+		Commit:   commit,
+		Mappings: mappings.Len(),
+		TLS:      *serveTLS,
+		Uptime:   time.Since(startTime).String(),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(st)
 }
@@ -0,0 +1,86 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(ioutil.Discard)
+	requestLog.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+// setFlag sets the named flag to value until the test ends.
+// Since pages depend on the flags, it empties the page cache
+// both now and then.
+func setFlag(t *testing.T, name, value string) {
+	t.Helper()
+	f := flag.Lookup(name)
+	if f == nil {
+		t.Fatalf("no flag -%s", name)
+	}
+	old := f.Value.String()
+	if err := f.Value.Set(value); err != nil {
+		t.Fatalf("-%s=%s: %v", name, value, err)
+	}
+	pages.reset()
+	t.Cleanup(func() {
+		f.Value.Set(old)
+		pages.reset()
+	})
+}
+
+// useMappings replaces the loaded couples, until the test ends, with those
+// read from config, in the config file format.
+func useMappings(t *testing.T, config string) {
+	t.Helper()
+	oldPath, oldMappings := filePath, mappings
+	t.Cleanup(func() {
+		filePath, mappings = oldPath, oldMappings
+		pages.reset()
+	})
+	if err := readConfig(writeTemp(t, "config.txt", config)); err != nil {
+		t.Fatal(err)
+	}
+	pages.reset()
+}
+
+// newHandler returns the handler of a server for the current couples
+// and flags, as main sets it up, with the routes of registerHandlers
+// on a new mux.
+func newHandler() http.HandlerFunc {
+	mux := http.NewServeMux()
+	registerHandlers(mux)
+	return newServer(underBasePath(mux)).Handler.ServeHTTP
+}
+
+// doRequest returns the response of h to a request with the given method
+// for url, which includes the host, as in http://rsc.io/x86?go-get=1.
+func doRequest(h http.HandlerFunc, method, url string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(method, url, nil))
+	return w
+}
+
+// goImportTag returns the content of the go-import meta tag
+// for importRoot in body, or "" if there is none.
+func goImportTag(body, importRoot string) string {
+	prefix := `<meta name="go-import" content="` + importRoot + " "
+	i := strings.Index(body, prefix)
+	if i < 0 {
+		return ""
+	}
+	tag := body[i+len(`<meta name="go-import" content="`):]
+	return tag[:strings.Index(tag, `"`)]
+}
@@ -0,0 +1,59 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// clientClasses are the labels by which requests are counted:
+// the go command, browsers, and anything else, such as crawlers.
+var clientClasses = [...]string{"go", "browser", "other"}
+
+// requestsByClient counts the requests served, indexed like clientClasses.
+var requestsByClient [len(clientClasses)]uint64
+
+// classifyClient returns the index in clientClasses of the kind of client
+// making req. The go command marks its requests with ?go-get=1 and
+// sends a Go-http-client user agent; browsers send one naming Mozilla.
+func classifyClient(req *http.Request) int {
+	ua := req.UserAgent()
+	switch {
+	case req.URL.Query().Get("go-get") == "1", strings.HasPrefix(ua, "Go-http-client/"), strings.Contains(ua, "go get"):
+		return 0
+	case strings.HasPrefix(ua, "Mozilla/"):
+		return 1
+	}
+	return 2
+}
+
+// countRequests returns a handler that counts each request by
+// its kind of client before passing it to h.
+func countRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddUint64(&requestsByClient[classifyClient(req)], 1)
+		h.ServeHTTP(w, req)
+	})
+}
+
+// adminMetrics reports the request counts in the OpenMetrics text format.
+func adminMetrics(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprintln(w, "# TYPE redirector_requests counter")
+	fmt.Fprintln(w, "# HELP redirector_requests Requests served, by kind of client.")
+	for i, class := range clientClasses {
+		fmt.Fprintf(w, "redirector_requests_total{client=%q} %d\n", class, atomic.LoadUint64(&requestsByClient[i]))
+	}
+	fmt.Fprintln(w, "# EOF")
+}
@@ -0,0 +1,38 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClassifyClient(t *testing.T) {
+	tests := []struct {
+		url   string
+		ua    string
+		class string
+	}{
+		{"http://rsc.io/x86?go-get=1", "", "go"},
+		{"http://rsc.io/x86?go-get=1", "Mozilla/5.0 (X11; Linux x86_64)", "go"},
+		{"http://rsc.io/x86", "Go-http-client/1.1", "go"},
+		{"http://rsc.io/x86", "Go-http-client/2.0", "go"},
+		{"http://rsc.io/x86", "go get (go1.21)", "go"},
+		{"http://rsc.io/x86", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7)", "browser"},
+		{"http://rsc.io/x86?go-get=0", "Mozilla/5.0", "browser"},
+		{"http://rsc.io/x86", "curl/8.4.0", "other"},
+		{"http://rsc.io/x86", "Googlebot/2.1", "other"},
+		{"http://rsc.io/x86", "", "other"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		if tt.ua != "" {
+			req.Header.Set("User-Agent", tt.ua)
+		}
+		if class := clientClasses[classifyClient(req)]; class != tt.class {
+			t.Errorf("classifyClient(%s with User-Agent %q) = %s, want %s", tt.url, tt.ua, class, tt.class)
+		}
+	}
+}
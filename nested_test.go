@@ -0,0 +1,37 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestNestedTags(t *testing.T) {
+	useMappings(t, `
+rsc.io/x https://github.com/rsc/x
+rsc.io/x/y https://github.com/rsc/y
+rsc.io/x/z https://github.com/rsc/z
+rsc.io/xyz https://github.com/rsc/xyz
+`)
+	w := doRequest(redirect, "GET", "http://rsc.io/x?go-get=1")
+	if w.Code != 200 {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	body := w.Body.String()
+	for root, want := range map[string]string{
+		"rsc.io/x":   "rsc.io/x git https://github.com/rsc/x",
+		"rsc.io/x/y": "rsc.io/x/y git https://github.com/rsc/y",
+		"rsc.io/x/z": "rsc.io/x/z git https://github.com/rsc/z",
+		"rsc.io/xyz": "",
+	} {
+		if got := goImportTag(body, root); got != want {
+			t.Errorf("go-import tag for %s = %q, want %q", root, got, want)
+		}
+	}
+
+	// A nested root lists only the couples below itself.
+	body = doRequest(redirect, "GET", "http://rsc.io/x/y?go-get=1").Body.String()
+	if got := goImportTag(body, "rsc.io/x/z"); got != "" {
+		t.Errorf("page for rsc.io/x/y has tag %q for rsc.io/x/z", got)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/noaleibo1/go-import-redirector/redirector"
+)
+
+func TestDocsHostOverride(t *testing.T) {
+	useMappings(t, `
+example.com/wiki https://github.com/ex/wiki docs-host=wiki.example.com/go
+example.com/plain https://github.com/ex/plain
+`)
+	tests := []struct {
+		url  string
+		docs string
+	}{
+		{"http://example.com/wiki/sub", "https://wiki.example.com/go/example.com/wiki/sub"},
+		{"http://example.com/plain/sub", "https://godoc.org/example.com/plain/sub"},
+	}
+	for _, tt := range tests {
+		w := doRequest(redirect, "GET", tt.url)
+		if body := w.Body.String(); !strings.Contains(body, `url=`+tt.docs+`"`) || !strings.Contains(body, `href="`+tt.docs+`"`) {
+			t.Errorf("%s: page does not redirect and link to %s:\n%s", tt.url, tt.docs, body)
+		}
+	}
+}
+
+func TestPerMappingOverrides(t *testing.T) {
+	useMappings(t, `{"mappings": [
+	{"import": "rsc.io/*", "repo": "https://github.com/rsc/*"},
+	{"import": "example.com/*", "repo": "https://github.com/example/*",
+	 "noDocs": true, "redirectStatus": 301, "docsHost": "pkg.go.dev"},
+	{"import": "golang.org/x/*", "repo": "https://go.googlesource.com/*", "redirectStatus": 301}
+]}`)
+
+	tests := []struct {
+		url      string
+		status   int
+		location string
+		docs     string
+	}{
+		// Defaults: 302 to the docs of the root, and pages redirecting to godoc.org.
+		{"http://rsc.io/", 302, "https://godoc.org/rsc.io", ""},
+		{"http://rsc.io/x86", 200, "", "https://godoc.org/rsc.io/x86"},
+		// Overrides: no docs at all, so no redirect for the root either.
+		{"http://example.com/", 404, "", ""},
+		{"http://example.com/x", 200, "", ""},
+		{"http://golang.org/x/", 301, "https://godoc.org/golang.org/x", ""},
+	}
+	for _, tt := range tests {
+		w := doRequest(redirect, "GET", tt.url)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.url, w.Code, tt.status)
+			continue
+		}
+		if loc := w.Header().Get("Location"); loc != tt.location {
+			t.Errorf("%s: Location = %q, want %q", tt.url, loc, tt.location)
+		}
+		if w.Code != 200 {
+			continue
+		}
+		body := w.Body.String()
+		if tt.docs == "" {
+			if strings.Contains(body, `http-equiv="refresh"`) {
+				t.Errorf("%s: page redirects to docs:\n%s", tt.url, body)
+			}
+		} else if !strings.Contains(body, `url=`+tt.docs+`"`) {
+			t.Errorf("%s: page does not redirect to %s:\n%s", tt.url, tt.docs, body)
+		}
+	}
+
+	// -redirect-status changes the default, not the override.
+	setFlag(t, "redirect-status", "301")
+	if w := doRequest(redirect, "GET", "http://rsc.io/"); w.Code != 301 {
+		t.Errorf("with -redirect-status=301, rsc.io/ status = %d, want 301", w.Code)
+	}
+	if w := doRequest(redirect, "GET", "http://golang.org/x/"); w.Code != 301 {
+		t.Errorf("with -redirect-status=301, golang.org/x/ status = %d, want 301", w.Code)
+	}
+}
+
+func TestOverrideValidation(t *testing.T) {
+	defer func(path string, m *redirector.Mappings) { filePath, mappings = path, m }(filePath, mappings)
+	for _, config := range []string{
+		"example.com/x https://github.com/ex/x redirect-status=307\n",
+		"example.com/x https://github.com/ex/x no-docs=maybe\n",
+		`{"mappings": [{"import": "example.com/x", "repo": "https://github.com/ex/x", "redirectStatus": 200}]}`,
+		`{"mappings": [{"import": "example.com/x", "repo": "https://github.com/ex/x", "noDoc": true}]}`,
+	} {
+		if err := readConfig(writeTemp(t, "config", config)); err == nil {
+			t.Errorf("config %q loaded, want an error", config)
+		}
+	}
+	for _, status := range []int{301, 302} {
+		if !validRedirectStatus(status) {
+			t.Errorf("validRedirectStatus(%d) = false", status)
+		}
+	}
+	for _, status := range []int{200, 303, 307, 308} {
+		if validRedirectStatus(status) {
+			t.Errorf("validRedirectStatus(%d) = true", status)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+
+	"github.com/noaleibo1/go-import-redirector/redirector"
+)
+
+// pageCacheSize bounds the number of rendered pages kept in pages,
+// so that requests for many distinct paths cannot exhaust memory.
+// Beyond it, the least recently used page is evicted.
+const pageCacheSize = 10000
+
+// pages holds the pages rendered by renderPage. The page for a request
+// depends only on its path and query, given the couples and settings,
+// so it is cleared whenever they change.
+var pages pageCache
+
+// A renderedPage is a page rendered from the template, with its ETag.
+type renderedPage struct {
+	key  string
+	body []byte
+	etag string
+}
+
+// A pageCache maps request paths and queries to their rendered pages,
+// keeping at most pageCacheSize of them. It is safe for concurrent use.
+type pageCache struct {
+	mu  sync.Mutex
+	m   map[string]*list.Element // of *renderedPage, in lru
+	lru list.List                // most recently used first
+	gen uint64                   // incremented by reset
+}
+
+// generation returns the number of times the cache has been reset.
+// A page rendered from couples resolved after a call to generation
+// may be put in the cache under the generation it returned.
+func (c *pageCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+func (c *pageCache) get(key string) *renderedPage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.m[key]
+	if e == nil {
+		return nil
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*renderedPage)
+}
+
+// put adds p for key, first evicting the least recently used page
+// if the cache is full. It drops p if the cache has been reset since
+// generation returned gen, since p may have been rendered from the
+// couples in use before.
+func (c *pageCache) put(key string, gen uint64, p *renderedPage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	if c.m == nil {
+		c.m = map[string]*list.Element{}
+	}
+	p.key = key
+	if e := c.m[key]; e != nil {
+		e.Value = p
+		c.lru.MoveToFront(e)
+		return
+	}
+	if c.lru.Len() >= pageCacheSize {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.m, e.Value.(*renderedPage).key)
+	}
+	c.m[key] = c.lru.PushFront(p)
+}
+
+// reset empties the cache.
+func (c *pageCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.m = nil
+	c.lru.Init()
+	c.gen++
+}
+
+// renderPage returns the page for d, served for the request path
+// and query given by key, rendering it only if it is not cached.
+// The page is cached only if d was resolved in generation gen.
+func renderPage(key string, gen uint64, d *redirector.Page) (*renderedPage, error) {
+	if p := pages.get(key); p != nil {
+		return p, nil
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, d); err != nil {
+		return nil, err
+	}
+	p := &renderedPage{
+		body: buf.Bytes(),
+		etag: fmt.Sprintf(`"%x"`, sha256.Sum256(buf.Bytes())),
+	}
+	pages.put(key, gen, p)
+	return p, nil
+}
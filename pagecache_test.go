@@ -0,0 +1,111 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/noaleibo1/go-import-redirector/redirector"
+)
+
+func TestPageCacheStalePut(t *testing.T) {
+	var c pageCache
+	gen := c.generation()
+	c.put("a", gen, &renderedPage{etag: "a"})
+	if c.get("a") == nil {
+		t.Fatal("page put in the current generation not cached")
+	}
+	c.reset()
+	c.put("b", gen, &renderedPage{etag: "b"})
+	if c.get("a") != nil || c.get("b") != nil {
+		t.Error("cache holds pages after reset")
+	}
+	c.put("c", c.generation(), &renderedPage{etag: "c"})
+	if c.get("c") == nil {
+		t.Error("page put after reset in the new generation not cached")
+	}
+}
+
+func TestPageCacheEviction(t *testing.T) {
+	var c pageCache
+	gen := c.generation()
+	for i := 0; i < pageCacheSize; i++ {
+		c.put(fmt.Sprint(i), gen, &renderedPage{})
+	}
+	c.get("0") // used recently, so 1 is the least recently used
+	c.put("new", gen, &renderedPage{})
+	if c.get("1") != nil {
+		t.Error("least recently used page kept in a full cache")
+	}
+	for _, key := range []string{"0", "2", "new"} {
+		if c.get(key) == nil {
+			t.Errorf("page %s evicted, want only the least recently used", key)
+		}
+	}
+}
+
+func TestPageCacheReload(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	w := doRequest(redirect, "GET", "http://rsc.io/x86?go-get=1")
+	if got := goImportTag(w.Body.String(), "rsc.io/x86"); got != "rsc.io/x86 git https://github.com/rsc/x86" {
+		t.Fatalf("go-import tag = %q", got)
+	}
+
+	if err := ioutil.WriteFile(filePath, []byte("rsc.io/x86 https://git.example.com/x86\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	gen := pages.generation()
+	d, _, _ := resolve("rsc.io/x86/")
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	// A request that resolved its page before the reload finishes after it.
+	if _, err := renderPage("rsc.io/x86/?", gen, d); err != nil {
+		t.Fatal(err)
+	}
+
+	w = doRequest(redirect, "GET", "http://rsc.io/x86?go-get=1")
+	if got := goImportTag(w.Body.String(), "rsc.io/x86"); got != "rsc.io/x86 git https://git.example.com/x86" {
+		t.Errorf("after reload, go-import tag = %q", got)
+	}
+}
+
+func BenchmarkRedirect(b *testing.B) {
+	m := redirector.NewMappings()
+	if err := readText(m, strings.NewReader("rsc.io/* https://github.com/rsc/*\n")); err != nil {
+		b.Fatal(err)
+	}
+	old := mappings
+	mappings = m
+	pages.reset()
+	defer func() {
+		mappings = old
+		pages.reset()
+	}()
+
+	for _, bb := range []struct {
+		name   string
+		cached bool
+	}{{"cached", true}, {"uncached", false}} {
+		b.Run(bb.name, func(b *testing.B) {
+			req := httptest.NewRequest("GET", "http://rsc.io/x86/x86asm?go-get=1", nil)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if !bb.cached {
+					pages.reset()
+				}
+				w := httptest.NewRecorder()
+				redirect(w, req)
+				if w.Code != 200 {
+					b.Fatalf("status = %d", w.Code)
+				}
+			}
+		})
+	}
+}
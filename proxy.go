@@ -0,0 +1,37 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// forwarded returns a handler that, with -trust-proxy, takes the host of
+// each request from the X-Forwarded-Host header set by the reverse proxy
+// in front of the redirector, then calls h. Without -trust-proxy the header
+// is ignored, since any client can send it.
+func forwarded(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if *trustProxy {
+			if host := lastForwarded(req, "X-Forwarded-Host"); host != "" && !strings.ContainsAny(host, "/\\ ") {
+				req.Host = host
+				req.URL.Host = host
+			}
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// lastForwarded returns the last entry of the comma-separated header key,
+// which is the one added by the proxy nearest the redirector, whether it
+// was appended to an existing line or sent on a line of its own.
+func lastForwarded(req *http.Request, key string) string {
+	v := strings.Join(req.Header[key], ",")
+	if i := strings.LastIndex(v, ","); i >= 0 {
+		v = v[i+1:]
+	}
+	return strings.ToLower(strings.TrimSpace(v))
+}
@@ -0,0 +1,92 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwarded(t *testing.T) {
+	tests := []struct {
+		name       string
+		trust      bool
+		remoteAddr string
+		header     map[string]string
+		host       string
+		ip         string
+	}{
+		{
+			name:       "trusted",
+			trust:      true,
+			remoteAddr: "10.0.0.1:1234",
+			header:     map[string]string{"X-Forwarded-Host": "rsc.io", "X-Forwarded-For": "203.0.113.7"},
+			host:       "rsc.io",
+			ip:         "203.0.113.7",
+		},
+		{
+			name:       "untrusted",
+			trust:      false,
+			remoteAddr: "10.0.0.1:1234",
+			header:     map[string]string{"X-Forwarded-Host": "rsc.io", "X-Forwarded-For": "203.0.113.7"},
+			host:       "backend.internal",
+			ip:         "10.0.0.1",
+		},
+		{
+			// The client sent its own headers; the proxy appended the real values.
+			name:       "spoofed",
+			trust:      true,
+			remoteAddr: "10.0.0.1:1234",
+			header:     map[string]string{"X-Forwarded-Host": "evil.example, rsc.io", "X-Forwarded-For": "198.51.100.1, 203.0.113.7"},
+			host:       "rsc.io",
+			ip:         "203.0.113.7",
+		},
+		{
+			name:       "malformed host",
+			trust:      true,
+			remoteAddr: "10.0.0.1:1234",
+			header:     map[string]string{"X-Forwarded-Host": "rsc.io/evil"},
+			host:       "backend.internal",
+			ip:         "10.0.0.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, "trust-proxy", map[bool]string{true: "true", false: "false"}[tt.trust])
+			req := httptest.NewRequest("GET", "http://backend.internal/x86", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			var host, ip string
+			forwarded(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				host = req.Host
+				ip = clientIP(req).String()
+			})).ServeHTTP(httptest.NewRecorder(), req)
+			if host != tt.host {
+				t.Errorf("host = %q, want %q", host, tt.host)
+			}
+			if ip != tt.ip {
+				t.Errorf("client IP = %q, want %q", ip, tt.ip)
+			}
+		})
+	}
+}
+
+func TestForwardedLines(t *testing.T) {
+	setFlag(t, "trust-proxy", "true")
+	req := httptest.NewRequest("GET", "http://backend.internal/x86", nil)
+	// The client sent its own header; the proxy added the real value on a line of its own.
+	req.Header.Add("X-Forwarded-Host", "evil.example")
+	req.Header.Add("X-Forwarded-Host", "rsc.io")
+	var host string
+	forwarded(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host = req.Host
+	})).ServeHTTP(httptest.NewRecorder(), req)
+	if host != "rsc.io" {
+		t.Errorf("host = %q, want rsc.io", host)
+	}
+}
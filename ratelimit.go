@@ -0,0 +1,119 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimitClients bounds the number of clients whose request rates are
+// tracked, so that memory stays bounded however many addresses are seen.
+// The least recently seen client is forgotten first.
+const rateLimitClients = 10000
+
+// limiter, if not nil, limits the rate of redirect requests from each client.
+var limiter *rateLimiter
+
+// A rateLimiter keeps a token bucket for each recently seen client.
+type rateLimiter struct {
+	rate  float64 // tokens added per second
+	burst float64 // capacity of each bucket
+	max   int     // number of buckets kept
+
+	mu      sync.Mutex
+	lru     *list.List // of *bucket, most recently used first
+	buckets map[string]*list.Element
+}
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a limiter for the rate given as n/unit,
+// such as 10/s or 600/m, allowing bursts of n requests.
+func newRateLimiter(spec string) (*rateLimiter, error) {
+	i := strings.Index(spec, "/")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid rate %q: want n/s, n/m, or n/h", spec)
+	}
+	n, err := strconv.Atoi(spec[:i])
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid rate %q: want n/s, n/m, or n/h", spec)
+	}
+	var per time.Duration
+	switch spec[i+1:] {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return nil, fmt.Errorf("invalid rate %q: want n/s, n/m, or n/h", spec)
+	}
+	return &rateLimiter{
+		rate:    float64(n) / per.Seconds(),
+		burst:   float64(n),
+		max:     rateLimitClients,
+		lru:     list.New(),
+		buckets: map[string]*list.Element{},
+	}, nil
+}
+
+// allow reports whether the client identified by key may make a request at now,
+// and if not, how long it should wait before trying again.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var b *bucket
+	if e := l.buckets[key]; e != nil {
+		l.lru.MoveToFront(e)
+		b = e.Value.(*bucket)
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+	} else {
+		if l.lru.Len() >= l.max {
+			old := l.lru.Remove(l.lru.Back()).(*bucket)
+			delete(l.buckets, old.key)
+		}
+		b = &bucket{key: key, tokens: l.burst, last: now}
+		l.buckets[key] = l.lru.PushFront(b)
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// rateLimited reports whether req exceeds the -rate-limit for its client
+// and if so replies with 429 Too Many Requests.
+func rateLimited(w http.ResponseWriter, req *http.Request) bool {
+	if limiter == nil {
+		return false
+	}
+	key := req.RemoteAddr
+	if ip := clientIP(req); ip != nil {
+		key = ip.String()
+	}
+	ok, wait := limiter.allow(key, time.Now())
+	if ok {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, "too many requests", http.StatusTooManyRequests)
+	return true
+}
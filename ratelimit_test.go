@@ -0,0 +1,68 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	defer func(old *rateLimiter) { limiter = old }(limiter)
+	get := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "http://rsc.io/x86?go-get=1", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		redirect(w, req)
+		return w
+	}
+
+	limiter = nil
+	for i := 0; i < 20; i++ {
+		if w := get("192.0.2.1:1234"); w.Code != 200 {
+			t.Fatalf("without -rate-limit: request %d: status %d, want 200", i+1, w.Code)
+		}
+	}
+
+	l, err := newRateLimiter("3/m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter = l
+	for i := 0; i < 3; i++ {
+		if w := get("192.0.2.1:1234"); w.Code != 200 {
+			t.Fatalf("-rate-limit=3/m: request %d: status %d, want 200", i+1, w.Code)
+		}
+	}
+	w := get("192.0.2.1:5678")
+	if retry := w.Header().Get("Retry-After"); w.Code != 429 || retry != "20" {
+		t.Errorf("-rate-limit=3/m: request 4: %d with Retry-After %q, want 429 with Retry-After 20", w.Code, retry)
+	}
+	// Other clients have buckets of their own.
+	if w := get("192.0.2.2:1234"); w.Code != 200 {
+		t.Errorf("-rate-limit=3/m: another client: status %d, want 200", w.Code)
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	l, err := newRateLimiter("2/s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("a", now); !ok {
+			t.Fatalf("request %d of a burst of 2 refused", i+1)
+		}
+	}
+	if ok, wait := l.allow("a", now); ok || wait != 500*time.Millisecond {
+		t.Errorf("third request = %v, %v, want refused for 500ms", ok, wait)
+	}
+	if ok, _ := l.allow("a", now.Add(500*time.Millisecond)); !ok {
+		t.Error("request after 500ms refused")
+	}
+}
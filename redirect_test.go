@@ -0,0 +1,477 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestContentType(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	for _, url := range []string{"http://rsc.io/x86?go-get=1", "http://rsc.io/x86/x86asm"} {
+		w := doRequest(redirect, "GET", url)
+		if got := w.Header().Get("Content-Type"); w.Code != 200 || got != "text/html; charset=utf-8" {
+			t.Errorf("%s: %d with Content-Type %q, want 200 with text/html; charset=utf-8", url, w.Code, got)
+		}
+	}
+}
+
+func TestSubdirTag(t *testing.T) {
+	useMappings(t, "example.com/tools https://github.com/example/monorepo subdir=tools\n")
+	for _, url := range []string{"http://example.com/tools?go-get=1", "http://example.com/tools/cmd/x?go-get=1"} {
+		body := doRequest(redirect, "GET", url).Body.String()
+		if got, want := goImportTag(body, "example.com/tools"), "example.com/tools git https://github.com/example/monorepo tools"; got != want {
+			t.Errorf("%s: go-import tag = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestBareRoot(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	if w := doRequest(newHandler(), "GET", "http://rsc.io/"); w.Code != 404 {
+		t.Errorf("rsc.io/ without -default-redirect: status %d, want 404", w.Code)
+	}
+
+	setFlag(t, "default-redirect", "https://example.com/home")
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	h := newHandler()
+	w := doRequest(h, "GET", "http://rsc.io/")
+	if w.Code != 302 || w.Header().Get("Location") != "https://example.com/home" {
+		t.Errorf("rsc.io/ with -default-redirect: %d %q, want 302 to https://example.com/home", w.Code, w.Header().Get("Location"))
+	}
+	// Other paths are unaffected.
+	if w := doRequest(h, "GET", "http://rsc.io/x86?go-get=1"); w.Code != 200 {
+		t.Errorf("rsc.io/x86 with -default-redirect: status %d, want 200", w.Code)
+	}
+	if w := doRequest(h, "GET", "http://rsc.io/pdf"); w.Code != 404 {
+		t.Errorf("rsc.io/pdf with -default-redirect: status %d, want 404", w.Code)
+	}
+}
+
+func TestDisabledCouple(t *testing.T) {
+	useMappings(t, `
+example.com/on https://github.com/ex/on enabled=true
+example.com/off https://github.com/ex/off enabled=false
+`)
+	if w := doRequest(redirect, "GET", "http://example.com/on?go-get=1"); w.Code != 200 || goImportTag(w.Body.String(), "example.com/on") == "" {
+		t.Errorf("enabled couple: status %d, want 200 with a go-import tag", w.Code)
+	}
+	w := doRequest(redirect, "GET", "http://example.com/off?go-get=1")
+	if w.Code != 404 || goImportTag(w.Body.String(), "example.com/off") != "" {
+		t.Errorf("disabled couple: status %d, want 404 without a go-import tag", w.Code)
+	}
+}
+
+func TestTrailingSlashUniform(t *testing.T) {
+	useMappings(t, `
+example.com/exact https://github.com/ex/exact
+example.com/w/* https://github.com/w/*
+example.com/g/** https://git.example.com/**
+`)
+	h := newHandler()
+	tests := []struct {
+		path string
+		tag  string
+	}{
+		{"/exact", "example.com/exact git https://github.com/ex/exact"},
+		{"/w/x", "example.com/w/x git https://github.com/w/x"},
+		{"/w/x/sub", "example.com/w/x git https://github.com/w/x"},
+		{"/g/a/b", "example.com/g/a/b git https://git.example.com/a/b"},
+	}
+	for _, tt := range tests {
+		for _, path := range []string{tt.path, tt.path + "/"} {
+			w := doRequest(h, "GET", "http://example.com"+path+"?go-get=1")
+			root := tt.tag[:strings.Index(tt.tag, " ")]
+			if got := goImportTag(w.Body.String(), root); w.Code != 200 || got != tt.tag {
+				t.Errorf("%s: %d with go-import tag %q, want 200 with %q", path, w.Code, got, tt.tag)
+			}
+		}
+	}
+}
+
+func TestQueryCarriedToDocs(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	w := doRequest(redirect, "GET", "http://rsc.io/x86/x86asm?tab=doc&go-get=1")
+	body := w.Body.String()
+	const docs = "https://godoc.org/rsc.io/x86/x86asm?tab=doc"
+	if !strings.Contains(body, `<meta http-equiv="refresh" content="0; url=`+docs+`">`) {
+		t.Errorf("refresh tag does not carry the query:\n%s", body)
+	}
+	if !strings.Contains(body, `<a href="`+docs+`">`) {
+		t.Errorf("docs link does not carry the query:\n%s", body)
+	}
+	if strings.Contains(body, "go-get") {
+		t.Errorf("go-get carried to the docs:\n%s", body)
+	}
+}
+
+func TestTraversalNotFound(t *testing.T) {
+	useMappings(t, "rsc.io/* https://github.com/rsc/*\n")
+	for _, path := range []string{
+		"/../../evil",
+		"/%2e%2e/evil",
+		"/x86/../../evil",
+		"/./x86",
+		"//evil",
+		"/.evil",
+		"/x86/.git/config",
+	} {
+		w := doRequest(redirect, "GET", "http://rsc.io"+path+"?go-get=1")
+		if body := w.Body.String(); w.Code != 404 || strings.Contains(body, "go-import") {
+			t.Errorf("%s: status %d with body %q, want 404 without a go-import tag", path, w.Code, body)
+		}
+	}
+}
+
+func TestMethods(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	h := newHandler()
+	const url = "http://rsc.io/x86?go-get=1"
+
+	if w := doRequest(h, "GET", url); w.Code != 200 || goImportTag(w.Body.String(), "rsc.io/x86") == "" {
+		t.Errorf("GET: status %d, want 200 with a go-import tag", w.Code)
+	}
+	if w := doRequest(h, "HEAD", url); w.Code != 200 || w.Body.Len() != 0 {
+		t.Errorf("HEAD: status %d with %d-byte body, want 200 without a body", w.Code, w.Body.Len())
+	}
+	for _, method := range []string{"POST", "PUT", "DELETE"} {
+		w := doRequest(h, method, url)
+		if allow := w.Header().Get("Allow"); w.Code != 405 || allow != "GET, HEAD" {
+			t.Errorf("%s: status %d with Allow %q, want 405 with Allow: GET, HEAD", method, w.Code, allow)
+		}
+	}
+}
+
+func TestHeadMatchesGet(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	h := newHandler()
+	for _, url := range []string{"http://rsc.io/x86?go-get=1", "http://rsc.io/x86/x86asm"} {
+		get := doRequest(h, "GET", url)
+		head := doRequest(h, "HEAD", url)
+		if head.Code != get.Code {
+			t.Errorf("%s: HEAD status %d, GET status %d", url, head.Code, get.Code)
+		}
+		for _, name := range []string{"Content-Type", "Content-Length", "Cache-Control", "ETag"} {
+			if got, want := head.Header().Get(name), get.Header().Get(name); got != want {
+				t.Errorf("%s: HEAD %s %q, GET %s %q", url, name, got, name, want)
+			}
+		}
+		if got, want := get.Header().Get("Content-Length"), strconv.Itoa(get.Body.Len()); got != want {
+			t.Errorf("%s: GET Content-Length %s for a %s-byte body", url, got, want)
+		}
+		if head.Body.Len() != 0 {
+			t.Errorf("%s: HEAD sent a %d-byte body", url, head.Body.Len())
+		}
+	}
+}
+
+func TestNoDocsRedirect(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	const url = "http://rsc.io/x86/x86asm"
+	if body := doRequest(redirect, "GET", url).Body.String(); !strings.Contains(body, `http-equiv="refresh"`) {
+		t.Errorf("without -no-docs-redirect: no refresh tag in %q", body)
+	}
+
+	setFlag(t, "no-docs-redirect", "true")
+	w := doRequest(redirect, "GET", url)
+	body := w.Body.String()
+	if strings.Contains(body, `http-equiv="refresh"`) || strings.Contains(body, "godoc.org") {
+		t.Errorf("with -no-docs-redirect: page links to the docs: %q", body)
+	}
+	if goImportTag(body, "rsc.io/x86") != "rsc.io/x86 git https://github.com/rsc/x86" {
+		t.Errorf("with -no-docs-redirect: go-import tag missing from %q", body)
+	}
+}
+
+func TestBareWildcardRootToDocs(t *testing.T) {
+	setFlag(t, "docsite", "pkg.go.dev")
+	useMappings(t, `
+rsc.io/* https://github.com/rsc/*
+example.com/* https://github.com/example/* docs-host=wiki.example.com/go
+`)
+	tests := []struct {
+		url      string
+		location string
+	}{
+		{"http://rsc.io/", "https://pkg.go.dev/rsc.io"},
+		{"http://example.com/", "https://wiki.example.com/go/example.com"},
+	}
+	for _, tt := range tests {
+		w := doRequest(newHandler(), "GET", tt.url)
+		loc := w.Header().Get("Location")
+		if w.Code != 302 || loc != tt.location {
+			t.Errorf("%s: %d to %q, want 302 to %s", tt.url, w.Code, loc, tt.location)
+		}
+		if strings.Contains(loc, "github.com") {
+			t.Errorf("%s: redirected to the repo %s", tt.url, loc)
+		}
+	}
+}
+
+func TestRedirectStatus(t *testing.T) {
+	for _, status := range []int{302, 301} {
+		setFlag(t, "redirect-status", strconv.Itoa(status))
+		setFlag(t, "default-redirect", "https://example.com/home")
+		useMappings(t, "rsc.io/* https://github.com/rsc/*\nexample.com/x https://github.com/example/x\n")
+		h := newHandler()
+		for _, url := range []string{"http://rsc.io/", "http://example.com/"} {
+			if w := doRequest(h, "GET", url); w.Code != status {
+				t.Errorf("-redirect-status=%d: %s: status %d", status, url, w.Code)
+			}
+		}
+	}
+}
+
+func TestTwoWildcards(t *testing.T) {
+	useMappings(t, "example.com/*/* https://git.example.com/*/*\n")
+	h := newHandler()
+	w := doRequest(h, "GET", "http://example.com/team/proj/sub?go-get=1")
+	if got, want := goImportTag(w.Body.String(), "example.com/team/proj"), "example.com/team/proj git https://git.example.com/team/proj"; w.Code != 200 || got != want {
+		t.Errorf("example.com/team/proj/sub: %d with go-import tag %q, want %q", w.Code, got, want)
+	}
+	// One element cannot fill two wildcards.
+	w = doRequest(h, "GET", "http://example.com/team?go-get=1")
+	if w.Code != 404 || strings.Contains(w.Body.String(), "go-import") {
+		t.Errorf("example.com/team: status %d, want 404 without a go-import tag", w.Code)
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	defer func(old *template.Template) { tmpl = old }(tmpl)
+	tmpl = template.Must(template.New("broken").Parse(`<html>{{.ImportRoot.Bogus}}</html>`))
+	var buf bytes.Buffer
+	requestLog.SetOutput(&buf)
+	defer requestLog.SetOutput(ioutil.Discard)
+
+	doRequest(redirect, "GET", "http://rsc.io/x86?go-get=1")
+	if !strings.Contains(buf.String(), "executing template for rsc.io/x86") {
+		t.Errorf("without -quiet: request log %q, want the template error reported", buf.String())
+	}
+
+	setFlag(t, "quiet", "true")
+	quietRequestLog()
+	buf.Reset()
+	doRequest(redirect, "GET", "http://rsc.io/x86?go-get=1")
+	if buf.Len() != 0 {
+		t.Errorf("with -quiet: request log %q, want nothing", buf.String())
+	}
+}
+
+func TestExactBeatsWildcard(t *testing.T) {
+	useMappings(t, `
+rsc.io/* https://github.com/rsc/*
+rsc.io/x86 https://git.example.com/x86
+`)
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(ioutil.Discard)
+	warnShadowed()
+	if want := "warning: rsc.io/x86 is also matched by rsc.io/*; the exact entry takes precedence"; !strings.Contains(buf.String(), want) {
+		t.Errorf("log %q, want %q", buf.String(), want)
+	}
+
+	for url, want := range map[string]string{
+		"http://rsc.io/x86/x86asm?go-get=1": "rsc.io/x86 git https://git.example.com/x86",
+		"http://rsc.io/pdf?go-get=1":        "rsc.io/pdf git https://github.com/rsc/pdf",
+	} {
+		root := strings.Fields(want)[0]
+		if got := goImportTag(doRequest(redirect, "GET", url).Body.String(), root); got != want {
+			t.Errorf("%s: go-import tag %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestAllowedHosts(t *testing.T) {
+	useMappings(t, "rsc.io/* https://github.com/rsc/*\n*.example.com/go https://github.com/*/go\n")
+	h := newHandler()
+	tests := []struct {
+		url    string
+		status int
+	}{
+		{"http://rsc.io/x86?go-get=1", 200},
+		{"http://foo.example.com/go?go-get=1", 200},
+		{"http://evil.com/x86?go-get=1", http.StatusMisdirectedRequest},
+		{"http://rsc.io.evil.com/x86?go-get=1", http.StatusMisdirectedRequest},
+		{"http://example.org/go?go-get=1", http.StatusMisdirectedRequest},
+	}
+	for _, tt := range tests {
+		w := doRequest(h, "GET", tt.url)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.url, w.Code, tt.status)
+		}
+		if tt.status != 200 && strings.Contains(w.Body.String(), "go-import") {
+			t.Errorf("%s: go-import tag served for a foreign host", tt.url)
+		}
+	}
+}
+
+func TestDocsTarget(t *testing.T) {
+	tests := []struct {
+		target string
+		docs   map[string]string // url to docs URL
+	}{
+		{"package", map[string]string{
+			"http://rsc.io/x86/x86asm/sub":     "https://godoc.org/rsc.io/x86/x86asm/sub",
+			"http://example.com/tools/cmd/vet": "https://godoc.org/example.com/tools/cmd/vet",
+		}},
+		{"module", map[string]string{
+			"http://rsc.io/x86/x86asm/sub":     "https://godoc.org/rsc.io/x86",
+			"http://example.com/tools/cmd/vet": "https://godoc.org/example.com/tools",
+		}},
+	}
+	for _, tt := range tests {
+		setFlag(t, "docs-target", tt.target)
+		useMappings(t, "rsc.io/* https://github.com/rsc/*\nexample.com/tools https://github.com/example/tools\n")
+		for url, docs := range tt.docs {
+			w := doRequest(redirect, "GET", url)
+			if link, want := w.Header().Get("Link"), "<"+docs+`>; rel="canonical"`; link != want {
+				t.Errorf("-docs-target=%s: %s: Link %q, want %q", tt.target, url, link, want)
+			}
+			if body := w.Body.String(); !strings.Contains(body, `url=`+docs+`"`) {
+				t.Errorf("-docs-target=%s: %s: page does not redirect to %s:\n%s", tt.target, url, docs, body)
+			}
+		}
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	useMappings(t, `
+rsc.io/x86 https://github.com/rsc/x86 maintenance=true
+rsc.io/pdf https://github.com/rsc/pdf
+`)
+	w := doRequest(redirect, "GET", "http://rsc.io/x86/x86asm?go-get=1")
+	if w.Code != 503 || w.Header().Get("Retry-After") != "3600" {
+		t.Errorf("maintenance couple: %d with Retry-After %q, want 503 with Retry-After 3600", w.Code, w.Header().Get("Retry-After"))
+	}
+	if body := w.Body.String(); strings.Contains(body, "go-import") || !strings.Contains(body, "rsc.io/x86") {
+		t.Errorf("maintenance couple: body %q, want a message naming rsc.io/x86 without a go-import tag", body)
+	}
+
+	w = doRequest(redirect, "GET", "http://rsc.io/pdf?go-get=1")
+	if got := goImportTag(w.Body.String(), "rsc.io/pdf"); w.Code != 200 || got != "rsc.io/pdf git https://github.com/rsc/pdf" {
+		t.Errorf("normal couple: %d with go-import tag %q", w.Code, got)
+	}
+}
+
+func TestCanonicalLink(t *testing.T) {
+	useMappings(t, `
+rsc.io/* https://github.com/rsc/*
+example.com/x https://github.com/example/x docs-host=pkg.go.dev
+`)
+	for url, docs := range map[string]string{
+		"http://rsc.io/x86":               "https://godoc.org/rsc.io/x86",
+		"http://rsc.io/x86/x86asm/":       "https://godoc.org/rsc.io/x86/x86asm",
+		"http://example.com/x/y?go-get=1": "https://pkg.go.dev/example.com/x/y",
+	} {
+		w := doRequest(redirect, "GET", url)
+		if body := w.Body.String(); !strings.Contains(body, `content="0; url=`+docs+`"`) {
+			t.Errorf("%s: page does not redirect to %s:\n%s", url, docs, body)
+		}
+		if link, want := w.Header().Get("Link"), "<"+docs+`>; rel="canonical"`; link != want {
+			t.Errorf("%s: Link %q, want %q", url, link, want)
+		}
+	}
+}
+
+func TestWildcardSubdirTag(t *testing.T) {
+	useMappings(t, `
+example.com/* https://github.com/example/monorepo subdir=modules/{name}
+example.com/x/*/* https://github.com/x/* subdir=go/*
+example.com/y/* https://github.com/example/y subdir=go/*/mod
+`)
+	tests := []struct {
+		url, root, tag string
+	}{
+		{"http://example.com/tools/cmd?go-get=1", "example.com/tools", "example.com/tools git https://github.com/example/monorepo modules/tools"},
+		{"http://example.com/x/repo/mod?go-get=1", "example.com/x/repo/mod", "example.com/x/repo/mod git https://github.com/x/repo go/mod"},
+		{"http://example.com/y/z?go-get=1", "example.com/y/z", "example.com/y/z git https://github.com/example/y go/z/mod"},
+	}
+	for _, tt := range tests {
+		if got := goImportTag(doRequest(redirect, "GET", tt.url).Body.String(), tt.root); got != tt.tag {
+			t.Errorf("%s: go-import tag %q, want %q", tt.url, got, tt.tag)
+		}
+	}
+}
+
+func TestTrailingSlashFlag(t *testing.T) {
+	tests := []struct {
+		mode     string
+		url      string
+		location string // or "" if served
+	}{
+		{"ignore", "http://rsc.io/x86/x86asm", ""},
+		{"ignore", "http://rsc.io/x86/x86asm/", ""},
+		{"add", "http://rsc.io/x86/x86asm", "/x86/x86asm/"},
+		{"add", "http://rsc.io/x86/x86asm?a=b", "/x86/x86asm/?a=b"},
+		{"add", "http://rsc.io/x86/x86asm/", ""},
+		{"add", "http://rsc.io/x86/x86asm?go-get=1", ""},
+		{"strip", "http://rsc.io/x86/x86asm/", "/x86/x86asm"},
+		{"strip", "http://rsc.io/x86/x86asm", ""},
+		{"strip", "http://rsc.io/x86/x86asm/?go-get=1", ""},
+	}
+	for _, tt := range tests {
+		setFlag(t, "trailing-slash", tt.mode)
+		useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+		w := doRequest(newHandler(), "GET", tt.url)
+		if tt.location == "" {
+			if w.Code != 200 || goImportTag(w.Body.String(), "rsc.io/x86") == "" {
+				t.Errorf("-trailing-slash=%s: %s: status %d, want the page", tt.mode, tt.url, w.Code)
+			}
+			continue
+		}
+		if loc := w.Header().Get("Location"); w.Code != 301 || loc != tt.location {
+			t.Errorf("-trailing-slash=%s: %s: %d to %q, want 301 to %s", tt.mode, tt.url, w.Code, loc, tt.location)
+		}
+	}
+}
+
+func TestContentSecurityPolicy(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	const url = "http://rsc.io/x86?go-get=1"
+	if got, want := doRequest(redirect, "GET", url).Header().Get("Content-Security-Policy"), "default-src 'none'; style-src 'unsafe-inline'"; got != want {
+		t.Errorf("default Content-Security-Policy %q, want %q", got, want)
+	}
+
+	const policy = "default-src 'self'; img-src https://example.com"
+	setFlag(t, "csp", policy)
+	if got := doRequest(redirect, "GET", url).Header().Get("Content-Security-Policy"); got != policy {
+		t.Errorf("-csp: Content-Security-Policy %q, want %q", got, policy)
+	}
+
+	setFlag(t, "csp", "")
+	if h := doRequest(redirect, "GET", url).Header(); len(h["Content-Security-Policy"]) != 0 {
+		t.Errorf("-csp=: Content-Security-Policy %q, want none", h["Content-Security-Policy"])
+	}
+}
+
+func TestMisdirectedVersusNotFound(t *testing.T) {
+	// With a config file, as main has, every host reaches redirect.
+	defer func(old string) { filePath = old }(filePath)
+	filePath = "redirects.txt"
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	h := newHandler()
+	tests := []struct {
+		url    string
+		status int
+	}{
+		{"http://other.io/x86?go-get=1", http.StatusMisdirectedRequest},
+		{"http://other.io/nope?go-get=1", http.StatusMisdirectedRequest},
+		{"http://rsc.io/nope?go-get=1", http.StatusNotFound},
+		{"http://rsc.io/x86?go-get=1", 200},
+	}
+	for _, tt := range tests {
+		if w := doRequest(h, "GET", tt.url); w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.url, w.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redirector
+
+import (
+	"bytes"
+	"crypto/tls"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// A Logger receives the messages of a handler returned by NewHandler.
+// A *log.Logger is a Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Options configures a handler returned by NewHandler.
+type Options struct {
+	// Logger receives messages about requests that failed.
+	// If nil, they are written to standard error.
+	Logger Logger
+
+	// TLSConfig, if not nil, is the TLS configuration of the server
+	// returned by NewServer, holding its certificates, client
+	// authentication, and cipher suites. A MinVersion of zero is
+	// raised to TLS 1.2.
+	TLSConfig *tls.Config
+
+	// NoDocs leaves the redirect to documentation out of all pages,
+	// as the NoDocs of a Couple does for its own.
+	NoDocs bool
+
+	// RedirectStatus is the status of HTTP redirects sent for couples
+	// whose RedirectStatus is 0, such as to the documentation of a bare
+	// wildcard root: 301 or 302. If 0, it is 302 Found.
+	RedirectStatus int
+
+	// Template renders the pages, executed with a *Page.
+	// If nil, PageTemplate is used. If executing it fails, the error
+	// is logged and a page holding only the go-import tags is served,
+	// with Cache-Control: no-store.
+	Template *template.Template
+}
+
+// NewServer returns a server for the handler returned by NewHandler,
+// with the TLS configuration of opts. To serve HTTPS with the
+// certificates of that configuration, call its ServeTLS or
+// ListenAndServeTLS methods with empty file names.
+func NewServer(m *Mappings, opts *Options) *http.Server {
+	srv := &http.Server{Handler: NewHandler(m, opts)}
+	if opts != nil && opts.TLSConfig != nil {
+		srv.TLSConfig = opts.TLSConfig.Clone()
+		if srv.TLSConfig.MinVersion == 0 {
+			srv.TLSConfig.MinVersion = tls.VersionTLS12
+		}
+	}
+	srv.ErrorLog = log.New(logWriter{srv.Handler.(*handler).opts.Logger}, "", 0)
+	return srv
+}
+
+// A logWriter passes the messages of a *log.Logger, such as those of
+// an http.Server, to a Logger.
+type logWriter struct {
+	l Logger
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.l.Printf("%s", bytes.TrimSuffix(p, []byte("\n")))
+	return len(p), nil
+}
+
+// NewHandler returns a handler serving the go-import page for each
+// GET or HEAD request whose host and path match one of the couples in m,
+// a redirect to the documentation for the bare root of a wildcard couple,
+// and 404 Not Found for any other. A nil opts uses the defaults.
+func NewHandler(m *Mappings, opts *Options) http.Handler {
+	h := &handler{m: m}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Logger == nil {
+		h.opts.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	if h.opts.Template == nil {
+		h.opts.Template = PageTemplate
+	}
+	return h
+}
+
+type handler struct {
+	m    *Mappings
+	opts Options
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	match := h.m.Match(req.Host + req.URL.Path)
+	if match == nil {
+		if docsURL, c := h.m.RootDocsURL(req.Host + req.URL.Path); docsURL != "" && !h.opts.NoDocs {
+			status := c.RedirectStatus
+			if status == 0 {
+				status = h.opts.RedirectStatus
+			}
+			if status == 0 {
+				status = http.StatusFound
+			}
+			http.Redirect(w, req, docsURL, status)
+			return
+		}
+		http.NotFound(w, req)
+		return
+	}
+	if match.Couple.Maintenance {
+		ServeMaintenance(w, match.ImportRoot)
+		return
+	}
+	var buf bytes.Buffer
+	page := match.Page()
+	page.NoDocs = page.NoDocs || h.opts.NoDocs
+	if err := h.opts.Template.Execute(&buf, page); err != nil {
+		// Keep ``go get'' working, but don't let caches keep the degraded page.
+		h.opts.Logger.Printf("executing template for %s: %v", req.Host+req.URL.Path, err)
+		buf.Reset()
+		WriteFallbackPage(&buf, page)
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	if req.Method == "HEAD" {
+		return
+	}
+	w.Write(buf.Bytes())
+}
+
+// MaintenanceRetryAfter is the Retry-After header, in seconds,
+// sent with the responses of ServeMaintenance.
+const MaintenanceRetryAfter = "3600"
+
+// ServeMaintenance replies to a request for a package under importRoot,
+// whose couple is under maintenance, with 503 Service Unavailable.
+func ServeMaintenance(w http.ResponseWriter, importRoot string) {
+	w.Header().Set("Retry-After", MaintenanceRetryAfter)
+	w.Header().Set("Cache-Control", "no-store")
+	http.Error(w, importRoot+" is temporarily unavailable for maintenance; try again later", http.StatusServiceUnavailable)
+}
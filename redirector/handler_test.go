@@ -0,0 +1,137 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redirector
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// A captureLogger records the messages it is given.
+type captureLogger struct {
+	msgs []string
+}
+
+func (l *captureLogger) Printf(format string, v ...interface{}) {
+	l.msgs = append(l.msgs, fmt.Sprintf(format, v...))
+}
+
+// serveHandler returns the response of h to a GET request for url.
+func serveHandler(h http.Handler, url string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+	return w
+}
+
+func TestHandlerPage(t *testing.T) {
+	m := NewMappings()
+	m.Add("rsc.io/x86/", &Couple{Repo: "https://github.com/rsc/x86"})
+	h := NewHandler(m, nil)
+	w := serveHandler(h, "http://rsc.io/x86/x86asm?go-get=1")
+	if w.Code != 200 {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`<meta name="go-import" content="rsc.io/x86 git https://github.com/rsc/x86">`,
+		`<meta http-equiv="refresh" content="0; url=https://godoc.org/rsc.io/x86/x86asm">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page lacks %s:\n%s", want, body)
+		}
+	}
+
+	if w := serveHandler(h, "http://rsc.io/pdf?go-get=1"); w.Code != 404 {
+		t.Errorf("unknown import path: status %d, want 404", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "http://rsc.io/x86", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("POST: status %d with Allow %q, want 405 with GET, HEAD", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestHandlerLogger(t *testing.T) {
+	m := NewMappings()
+	m.Add("rsc.io/x86/", &Couple{Repo: "https://github.com/rsc/x86"})
+	logger := new(captureLogger)
+	broken := template.Must(template.New("broken").Parse(`{{.ImportRoot.Bogus}}`))
+
+	serveHandler(NewHandler(m, &Options{Logger: logger, Template: broken}), "http://rsc.io/x86?go-get=1")
+	if len(logger.msgs) != 1 || !strings.Contains(logger.msgs[0], "executing template for rsc.io/x86") {
+		t.Errorf("logged %q, want one message about the template", logger.msgs)
+	}
+}
+
+func TestHandlerTemplateFallback(t *testing.T) {
+	m := NewMappings()
+	m.Add("rsc.io/x86/", &Couple{Repo: "https://github.com/rsc/x86"})
+	m.Add("rsc.io/x86/arch/", &Couple{Repo: "https://github.com/rsc/arch"})
+	broken := template.Must(template.New("broken").Parse(`<html>{{.ImportRoot.Bogus}}</html>`))
+
+	w := serveHandler(NewHandler(m, &Options{Logger: new(captureLogger), Template: broken}), "http://rsc.io/x86?go-get=1")
+	if w.Code != 200 {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	body := w.Body.String()
+	for _, tag := range []string{
+		`<meta name="go-import" content="rsc.io/x86 git https://github.com/rsc/x86">`,
+		`<meta name="go-import" content="rsc.io/x86/arch git https://github.com/rsc/arch">`,
+	} {
+		if !strings.Contains(body, tag) {
+			t.Errorf("fallback page lacks %s:\n%s", tag, body)
+		}
+	}
+	if strings.HasPrefix(body, "<html>") {
+		t.Errorf("served the partial template output:\n%s", body)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", cc)
+	}
+}
+
+func TestHandlerOverrides(t *testing.T) {
+	m := NewMappings()
+	m.Add("rsc.io/*/", &Couple{Repo: "https://github.com/rsc/*"})
+	m.Add("example.com/*/", &Couple{Repo: "https://github.com/example/*", NoDocs: true, RedirectStatus: 301})
+	m.Add("golang.org/x/*/", &Couple{Repo: "https://go.googlesource.com/*", RedirectStatus: 301})
+
+	tests := []struct {
+		name     string
+		opts     *Options
+		url      string
+		status   int
+		location string
+		docs     bool
+	}{
+		{"defaults page", nil, "http://rsc.io/x86", 200, "", true},
+		{"defaults root", nil, "http://rsc.io", 302, "https://godoc.org/rsc.io", false},
+		{"overrides page", nil, "http://example.com/x", 200, "", false},
+		{"overrides root", nil, "http://example.com", 404, "", false},
+		{"status override root", nil, "http://golang.org/x", 301, "https://godoc.org/golang.org/x", false},
+		{"server status root", &Options{RedirectStatus: 301}, "http://rsc.io", 301, "https://godoc.org/rsc.io", false},
+		{"server no docs page", &Options{NoDocs: true}, "http://rsc.io/x86", 200, "", false},
+		{"server no docs root", &Options{NoDocs: true}, "http://rsc.io", 404, "", false},
+	}
+	for _, tt := range tests {
+		w := serveHandler(NewHandler(m, tt.opts), tt.url)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+			continue
+		}
+		if loc := w.Header().Get("Location"); loc != tt.location {
+			t.Errorf("%s: Location = %q, want %q", tt.name, loc, tt.location)
+		}
+		if w.Code == 200 {
+			if docs := strings.Contains(w.Body.String(), `http-equiv="refresh"`); docs != tt.docs {
+				t.Errorf("%s: page redirects to docs = %v, want %v", tt.name, docs, tt.docs)
+			}
+		}
+	}
+}
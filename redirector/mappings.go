@@ -0,0 +1,350 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redirector
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Mappings holds the configured couples, the per-host VCS defaults,
+// and the settings used to resolve requests against them.
+// It is safe for concurrent use, so that handlers can read it
+// while it is being loaded or changed.
+type Mappings struct {
+	// WildcardDots says what to do with a * element containing dots:
+	// keep it as it is ("keep" or ""), replace the dots with DotReplacement
+	// in the repo path ("replace"), or treat the path as not found ("reject").
+	// It and DotReplacement must not be changed while the Mappings are in use.
+	WildcardDots   string
+	DotReplacement string
+
+	// DocsTarget says which page of the documentation site a request
+	// is sent to: that of the requested package ("package" or ""),
+	// or that of the matched import root ("module").
+	// It must not be changed while the Mappings are in use.
+	DocsTarget string
+
+	// AppendGitSuffix says whether .git is appended to the repo URLs
+	// of git couples that lack it in go-import tags, for hosts that
+	// require it. It must not be changed while the Mappings are in use.
+	AppendGitSuffix bool
+
+	mu         sync.RWMutex
+	exact      map[string]*Couple            // couples without a wildcard, by import path
+	below      map[string]map[string]*Couple // exact, by each element prefix strictly above the import path
+	wildcard   map[string]*Couple            // wildcard couples, by import template
+	tree       *pathTree                     // index of wildcard
+	hosts      map[string]bool               // hosts of couples, such as rsc.io or *.example.com
+	hostVCS    map[string]string
+	defaultVCS string
+	docsHost   string
+
+	defaultRedirect string
+
+	reMu   sync.Mutex
+	repoRE map[string]*regexp.Regexp // compiled repo templates of wildcard couples, for Reverse
+}
+
+// NewMappings returns empty Mappings that serve git repos
+// and send readers to documentation on godoc.org.
+func NewMappings() *Mappings {
+	return &Mappings{
+		exact:      map[string]*Couple{},
+		below:      map[string]map[string]*Couple{},
+		wildcard:   map[string]*Couple{},
+		tree:       newPathTree(),
+		hosts:      map[string]bool{},
+		hostVCS:    map[string]string{},
+		defaultVCS: "git",
+		docsHost:   "godoc.org",
+	}
+}
+
+// IsWildcard reports whether importPath is a wildcard template,
+// with a * standing for a path element or the first label of the host,
+// or a ** for the remaining path elements.
+func IsWildcard(importPath string) bool {
+	return strings.HasPrefix(importPath, "*.") || strings.Contains(importPath, "/*/") || strings.HasSuffix(importPath, "/**/")
+}
+
+// Replace replaces the couples of m with those of n, together with the
+// defaults a config sets: the per-host and default VCS and the default
+// redirect. It keeps the settings of m, including its documentation host,
+// and returns the previous couples and defaults as new Mappings.
+func (m *Mappings) Replace(n *Mappings) *Mappings {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old := &Mappings{exact: m.exact, below: m.below, wildcard: m.wildcard, tree: m.tree, hosts: m.hosts, hostVCS: m.hostVCS,
+		defaultVCS: m.defaultVCS, defaultRedirect: m.defaultRedirect}
+	m.exact, m.below, m.wildcard, m.tree, m.hosts, m.hostVCS = n.exact, n.below, n.wildcard, n.tree, n.hosts, n.hostVCS
+	m.defaultVCS, m.defaultRedirect = n.defaultVCS, n.defaultRedirect
+	m.reMu.Lock()
+	m.repoRE = nil
+	m.reMu.Unlock()
+	return old
+}
+
+// Add adds the couple c for importPath, a normalized import path ending in a slash,
+// replacing any couple already configured for it.
+func (m *Mappings) Add(importPath string, c *Couple) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hosts[importPath[:strings.Index(importPath, "/")]] = true
+	if IsWildcard(importPath) {
+		m.wildcard[importPath] = c
+		m.tree.add(importPath, c)
+		return
+	}
+	m.exact[importPath] = c
+	for i := strings.Index(importPath, "/"); i+1 < len(importPath); i += 1 + strings.Index(importPath[i+1:], "/") {
+		prefix := importPath[:i+1]
+		if m.below[prefix] == nil {
+			m.below[prefix] = map[string]*Couple{}
+		}
+		m.below[prefix][importPath] = c
+	}
+}
+
+// Get returns the couple configured for importPath, exactly as given
+// to Add, or nil if there is none.
+func (m *Mappings) Get(importPath string) *Couple {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if c := m.exact[importPath]; c != nil {
+		return c
+	}
+	return m.wildcard[importPath]
+}
+
+// HasHost reports whether any couple is configured for an import path
+// on host, either exactly or through a wildcard first label.
+func (m *Mappings) HasHost(host string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.hosts[host] {
+		return true
+	}
+	i := strings.Index(host, ".")
+	return i > 0 && m.hosts["*"+host[i:]]
+}
+
+// SetHostVCS sets the default version control system for couples under host.
+func (m *Mappings) SetHostVCS(host, vcs string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hostVCS[host] = vcs
+}
+
+// SetDefaultVCS sets the version control system for couples
+// that name none and whose host has no default of its own.
+func (m *Mappings) SetDefaultVCS(vcs string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.defaultVCS = vcs
+}
+
+// VCSFor returns the version control system for c, served under importRoot:
+// the couple's own if set, else the default for the host or, for a host
+// matched by a wildcard first label, for the wildcard host such as
+// *.example.com, else the one indicated by the couple's repo URL, else
+// the default set by SetDefaultVCS.
+func (m *Mappings) VCSFor(importRoot string, c *Couple) string {
+	if c.VCS != "" {
+		return c.VCS
+	}
+	host := importRoot
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if v := m.hostVCS[host]; v != "" {
+		return v
+	}
+	if i := strings.Index(host, "."); i > 0 && host[:i] != "*" {
+		if v := m.hostVCS["*"+host[i:]]; v != "" {
+			return v
+		}
+	}
+	if v := RepoVCS(c.Repo); v != "" {
+		return v
+	}
+	return m.defaultVCS
+}
+
+// repoHostVCS maps the hosts known to serve a single version
+// control system to that system.
+var repoHostVCS = map[string]string{
+	"github.com":    "git",
+	"gitlab.com":    "git",
+	"bitbucket.org": "git",
+	"codeberg.org":  "git",
+	"git.sr.ht":     "git",
+	"hg.sr.ht":      "hg",
+}
+
+// RepoVCS returns the version control system indicated by the repo URL,
+// from its suffix, such as .git, its scheme, such as svn+ssh, or its host,
+// such as github.com or hg.example.com, or "" if it indicates none.
+func RepoVCS(repo string) string {
+	u, err := url.Parse(repo)
+	if err != nil {
+		return ""
+	}
+	for _, vcs := range []string{"git", "hg", "svn", "bzr", "fossil"} {
+		if strings.HasSuffix(strings.TrimSuffix(u.Path, "/"), "."+vcs) {
+			return vcs
+		}
+	}
+	switch strings.TrimSuffix(u.Scheme, "+ssh") {
+	case "git", "svn", "bzr":
+		return strings.TrimSuffix(u.Scheme, "+ssh")
+	}
+	host := strings.ToLower(u.Hostname())
+	if vcs := repoHostVCS[host]; vcs != "" {
+		return vcs
+	}
+	if i := strings.Index(host, "."); i > 0 {
+		switch label := host[:i]; label {
+		case "git", "hg", "svn":
+			return label
+		}
+	}
+	return ""
+}
+
+// SetDefaultRedirect sets the URL to which requests for the bare root of
+// a host are redirected when no couple serves it, or "" for none.
+func (m *Mappings) SetDefaultRedirect(url string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.defaultRedirect = url
+}
+
+// DefaultRedirect returns the URL set by SetDefaultRedirect.
+func (m *Mappings) DefaultRedirect() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.defaultRedirect
+}
+
+// SetDocsHost sets the host serving documentation for couples that name none.
+func (m *Mappings) SetDocsHost(host string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.docsHost = host
+}
+
+// DocsHost returns the host serving documentation for couples that name none.
+func (m *Mappings) DocsHost() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.docsHost
+}
+
+// DocsSite returns the documentation site for c's packages, both as a URL
+// to which an import path is appended and as shown to readers, without https://.
+func (m *Mappings) DocsSite(c *Couple) (base, shown string) {
+	shown = c.DocsHost
+	if shown == "" {
+		shown = m.DocsHost()
+	}
+	shown = strings.TrimRight(shown, "/")
+	if strings.Contains(shown, "://") {
+		return shown, strings.TrimPrefix(shown, "https://")
+	}
+	return "https://" + shown, shown
+}
+
+// Len returns the number of configured couples.
+func (m *Mappings) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.exact) + len(m.wildcard)
+}
+
+// All returns a copy of the configured couples, keyed by import path or template.
+func (m *Mappings) All() map[string]*Couple {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	all := make(map[string]*Couple, len(m.exact)+len(m.wildcard))
+	for k, c := range m.exact {
+		all[k] = c
+	}
+	for k, c := range m.wildcard {
+		all[k] = c
+	}
+	return all
+}
+
+// Shadowed returns the import paths of the couples without a wildcard
+// that a wildcard couple also matches with the same import root,
+// mapped to the template of that wildcard couple. Match serves such paths
+// from the couple without a wildcard.
+func (m *Mappings) Shadowed() map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	templates := map[*Couple]string{}
+	for template, c := range m.wildcard {
+		templates[c] = template
+	}
+	shadowed := map[string]string{}
+	for importPath := range m.exact {
+		if root, _, c, ok := m.tree.lookup(importPath); ok && root == importPath {
+			shadowed[importPath] = templates[c]
+		}
+	}
+	return shadowed
+}
+
+// lookup returns the longest non-wildcard import path that path falls under,
+// trying each element prefix of path, ending in a slash, longest first.
+func (m *Mappings) lookup(path string) (string, *Couple, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for prefix := path; prefix != ""; {
+		if c := m.exact[prefix]; c != nil {
+			return prefix, c, true
+		}
+		i := strings.LastIndex(prefix[:len(prefix)-1], "/")
+		prefix = prefix[:i+1]
+	}
+	return "", nil, false
+}
+
+// under returns the couples without a wildcard whose import paths
+// lie strictly below root, as indexed by Add.
+func (m *Mappings) under(root string) map[string]*Couple {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	below := make(map[string]*Couple, len(m.below[root]))
+	for importPath, c := range m.below[root] {
+		below[importPath] = c
+	}
+	return below
+}
+
+// WildcardRoot returns the wildcard couple whose template is path,
+// ending in a slash, followed by a * or ** element, or nil if there is none.
+func (m *Mappings) WildcardRoot(path string) *Couple {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if c := m.wildcard[path+"*/"]; c != nil {
+		return c
+	}
+	return m.wildcard[path+"**/"]
+}
+
+// lookupWildcard finds the wildcard couple matching path.
+// It returns the import root, which is the prefix of path matching the
+// couple's import template, and the elements matched by its wildcards, in order.
+func (m *Mappings) lookupWildcard(path string) (root string, elems []string, c *Couple, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.tree.lookup(path)
+}
@@ -0,0 +1,105 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redirector
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentAccess matches import paths while couples are added
+// and replaced; run it with -race.
+func TestConcurrentAccess(t *testing.T) {
+	m := NewMappings()
+	m.Add("rsc.io/*/", &Couple{Repo: "https://github.com/rsc/*"})
+	m.Add("example.com/x/", &Couple{Repo: "https://github.com/ex/x"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if match := m.Match("rsc.io/x86/x86asm"); match == nil || match.VCSRoot != "https://github.com/rsc/x86" {
+					t.Errorf("Match(rsc.io/x86/x86asm) = %+v", match)
+					return
+				}
+				m.Match("example.com/x")
+				m.WildcardRoot("rsc.io/")
+				m.All()
+				m.Len()
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 200; j++ {
+			m.Add(fmt.Sprintf("example.com/p%d/", j), &Couple{Repo: fmt.Sprintf("https://github.com/ex/p%d", j)})
+			m.SetHostVCS("example.com", "git")
+			n := NewMappings()
+			n.Add("rsc.io/*/", &Couple{Repo: "https://github.com/rsc/*"})
+			m.Replace(n)
+		}
+	}()
+	wg.Wait()
+}
+
+func TestRepoVCS(t *testing.T) {
+	tests := []struct {
+		repo string
+		vcs  string
+	}{
+		{"https://github.com/rsc/x86", "git"},
+		{"https://GitHub.com/rsc/x86", "git"},
+		{"https://example.com/repo.git", "git"},
+		{"https://example.com/repo.git/", "git"},
+		{"https://example.com/repo.hg", "hg"},
+		{"https://example.com/repo.fossil", "fossil"},
+		{"git://example.com/repo", "git"},
+		{"svn+ssh://example.com/repo", "svn"},
+		{"bzr://example.com/repo", "bzr"},
+		{"https://hg.sr.ht/~rsc/x86", "hg"},
+		{"https://hg.example.com/x86", "hg"},
+		{"https://svn.example.com:8443/x86", "svn"},
+		{"https://code.example.com/x86", ""},
+		{"https://example.com/git/x86", ""},
+		{"://bad", ""},
+	}
+	for _, tt := range tests {
+		if vcs := RepoVCS(tt.repo); vcs != tt.vcs {
+			t.Errorf("RepoVCS(%q) = %q, want %q", tt.repo, vcs, tt.vcs)
+		}
+	}
+}
+
+func TestVCSForPrecedence(t *testing.T) {
+	m := NewMappings()
+	m.Add("*.example.com/x/", &Couple{Repo: "https://code.example.com/*/x"})
+	m.Add("*.example.com/y/", &Couple{Repo: "https://github.com/*/y"})
+	m.Add("*.example.com/z/", &Couple{Repo: "https://code.example.com/*/z", VCS: "fossil"})
+	m.Add("other.org/x/", &Couple{Repo: "https://code.other.org/x"})
+	m.SetHostVCS("*.example.com", "hg")
+	m.SetHostVCS("b.example.com", "svn")
+	m.SetDefaultVCS("bzr")
+
+	tests := []struct {
+		path string
+		vcs  string
+	}{
+		{"a.example.com/x", "hg"},     // default of the wildcard host
+		{"a.example.com/y", "hg"},     // host default before the repo URL
+		{"b.example.com/x", "svn"},    // exact host before the wildcard host
+		{"a.example.com/z", "fossil"}, // couple's own first
+		{"other.org/x", "bzr"},        // global default last
+	}
+	for _, tt := range tests {
+		match := m.Match(tt.path)
+		if match == nil || match.VCS != tt.vcs {
+			t.Errorf("Match(%q) = %+v, want vcs %s", tt.path, match, tt.vcs)
+		}
+	}
+}
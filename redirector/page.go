@@ -0,0 +1,93 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redirector
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// A Page holds what a redirect page is rendered from: the go-import tag
+// and those nested below it, the go-source tag, and the documentation link.
+// Templates such as PageTemplate are executed with a *Page.
+type Page struct {
+	GoImport // tag for the matched couple
+
+	// Nested lists the go-import tags of the couples whose import roots
+	// lie below ImportRoot, as in Match.
+	Nested []GoImport
+
+	GoSource string // content of the go-source tag following ImportRoot, if any
+	Suffix   string // rest of the import path after ImportRoot
+	DocsHost string // documentation site, as shown to readers
+	DocsURL  string // documentation page for the import path
+	NoDocs   bool   // omit the redirect to DocsURL
+}
+
+// Page returns the page served for the match,
+// without a redirect to documentation if its couple has NoDocs.
+func (m *Match) Page() *Page {
+	return &Page{
+		GoImport: m.GoImport,
+		Nested:   m.Nested,
+		GoSource: m.GoSource,
+		Suffix:   m.Suffix,
+		DocsHost: m.DocsHost,
+		DocsURL:  m.DocsURL,
+		NoDocs:   m.Couple.NoDocs,
+	}
+}
+
+// RootDocsURL returns the documentation page to which a request for
+// importPath is redirected when it names the bare root of an enabled
+// wildcard couple, such as rsc.io for rsc.io/*, with that couple.
+// The root itself is no package, but its documentation lists the packages
+// below it. RootDocsURL returns "" and nil for any other import path,
+// or if the couple has NoDocs.
+func (m *Mappings) RootDocsURL(importPath string) (string, *Couple) {
+	path := strings.TrimRight(importPath, "/") + "/"
+	c := m.WildcardRoot(path)
+	if c == nil || c.Disabled || c.NoDocs {
+		return "", nil
+	}
+	// The repo URL must not leak in here: it is a template,
+	// not a path on the docs site.
+	base, _ := m.DocsSite(c)
+	return base + "/" + strings.TrimSuffix(path, "/"), c
+}
+
+// PageTemplate is the built-in template for redirect pages.
+// It writes the go-import and go-source meta tags and, unless NoDocs
+// is set, a redirect to the documentation page.
+var PageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+<meta name="go-import" content="{{.ImportRoot}} {{.VCS}} {{.VCSRoot}}{{with .Subdir}} {{.}}{{end}}">
+{{range .Nested}}<meta name="go-import" content="{{.ImportRoot}} {{.VCS}} {{.VCSRoot}}{{with .Subdir}} {{.}}{{end}}">
+{{end -}}
+{{with .GoSource}}<meta name="go-source" content="{{$.ImportRoot}} {{.}}">
+{{end -}}
+{{if not .NoDocs}}<meta http-equiv="refresh" content="0; url={{.DocsURL}}">
+{{end -}}
+</head>
+<body>
+{{if not .NoDocs}}Redirecting to docs at <a href="{{.DocsURL}}">{{.DocsHost}}/{{.ImportRoot}}{{.Suffix}}</a>...
+{{end -}}
+</body>
+</html>
+`))
+
+// WriteFallbackPage writes a minimal page holding only the go-import tags of p.
+// It is served in place of the template's output if executing the template fails.
+func WriteFallbackPage(w io.Writer, p *Page) {
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta name=\"go-import\" content=\"%s\">\n", template.HTMLEscapeString(p.GoImport.String()))
+	for _, t := range p.Nested {
+		fmt.Fprintf(w, "<meta name=\"go-import\" content=\"%s\">\n", template.HTMLEscapeString(t.String()))
+	}
+	fmt.Fprintf(w, "</head>\n</html>\n")
+}
@@ -0,0 +1,308 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package redirector resolves import paths on a custom Go import domain
+// to the go-import tags and documentation pages configured for them.
+// It holds the matching logic of go-import-redirector, without any
+// dependence on flags or global state, so that a configuration
+// can be checked by importing it directly, and NewHandler serves
+// the resulting pages from within another program.
+package redirector
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// A Couple is a single configured mapping from an import path to a repo.
+type Couple struct {
+	// Repo is the repo URL. For a wildcard couple, its * and ** elements
+	// and Placeholder tokens are replaced, in order, by the parts of the
+	// import path matched by the wildcards of the import template.
+	// If the couple has Hosts, the first of those parts instead selects
+	// the host that replaces the HostPlaceholder token.
+	Repo string
+
+	// Hosts maps the path element matched by the first wildcard of a
+	// wildcard couple to the host, optionally followed by a path,
+	// substituted for the HostPlaceholder of Repo. The host for the key "*"
+	// is used for elements not listed; without it, they are not found.
+	Hosts map[string]string
+
+	// CacheControl is the Cache-Control header sent for the couple's pages.
+	// If empty, it is derived from the ref in the repo URL, if any.
+	CacheControl string
+
+	// Ref is the branch, tag, or commit appended to the repo URL
+	// as a fragment in the go-import tag, or "" for none.
+	Ref string
+
+	// Subdir is the directory within the repo holding the module
+	// rooted at the import path, or "" for the repo's root directory.
+	// For a wildcard couple, its * elements and Placeholder tokens are
+	// replaced like those of Repo, by the parts of the import path
+	// left over once those of Repo are replaced.
+	Subdir string
+
+	// VCS is the couple's version control system.
+	// If empty, the default for the import path's host is used.
+	VCS string
+
+	// GoSource is the content of a go-source meta tag following the
+	// import path: the home, directory, and file URL templates.
+	// If empty, no go-source tag is served.
+	GoSource string
+
+	// Disabled marks a couple as switched off: its import paths are not found.
+	Disabled bool
+
+	// Maintenance marks a couple as temporarily unavailable: its import paths
+	// are matched, but answered with 503 Service Unavailable instead of a page.
+	Maintenance bool
+
+	// Source is the config file the couple was read from, if any.
+	Source string
+
+	// Depth, if positive, limits the number of path elements
+	// matched by a ** wildcard.
+	Depth int
+
+	// NoDocs marks a couple whose pages carry no redirect to documentation.
+	NoDocs bool
+
+	// RedirectStatus is the status of HTTP redirects sent for the couple,
+	// such as to the documentation of a bare wildcard root,
+	// or 0 for the server's default.
+	RedirectStatus int
+
+	// DocsHost is the host, optionally followed by a path, serving
+	// documentation for the couple's packages, or a URL with the scheme.
+	// If empty, the documentation host of the Mappings is used.
+	DocsHost string
+}
+
+// A GoImport is the content of a go-import meta tag.
+type GoImport struct {
+	ImportRoot string
+	VCS        string
+	VCSRoot    string
+	Subdir     string
+}
+
+func (t GoImport) String() string {
+	content := t.ImportRoot + " " + t.VCS + " " + t.VCSRoot
+	if t.Subdir != "" {
+		content += " " + t.Subdir
+	}
+	return content
+}
+
+// A Match describes what is served for an import path.
+type Match struct {
+	GoImport         // tag for the matched couple
+	Couple   *Couple // the matched couple
+
+	// Nested lists the go-import tags of the couples whose import roots
+	// lie below ImportRoot, such as the other modules of a monorepo.
+	// The go command uses the tag whose root is a prefix of the import path
+	// it is after, so these never compete with the tag for ImportRoot.
+	Nested []GoImport
+
+	GoSource string // content of the go-source tag following ImportRoot, if any
+	Suffix   string // rest of the import path after ImportRoot, such as /x86asm, or "" for DocsTarget "module"
+	DocsHost string // documentation site, as shown to readers
+	DocsURL  string // documentation page for the import path
+}
+
+// Resolve returns the go-import tag and documentation URL served for
+// the import path made of host and path, such as rsc.io and /x86/x86asm.
+// It reports false if no enabled couple matches.
+func Resolve(host, path string, m *Mappings) (tag GoImport, docsURL string, ok bool) {
+	match := m.Match(host + path)
+	if match == nil {
+		return GoImport{}, "", false
+	}
+	return match.GoImport, match.DocsURL, true
+}
+
+// Match matches importPath, such as rsc.io/x86/x86asm, against the couples.
+// Trailing slashes are ignored, so that the Suffix of the result
+// is either empty or a slash followed by the rest of the import path.
+// The couple with the longest matching import root wins, whether it
+// has a wildcard or not; for equally long roots, the one without a wildcard wins.
+// Match returns nil if no enabled couple matches.
+func (m *Mappings) Match(importPath string) *Match {
+	path := strings.TrimRight(importPath, "/") + "/"
+	if !validPath(path) {
+		return nil
+	}
+	exactRoot, exact, exactOK := m.lookup(path)
+	root, elems, wild, wildOK := m.lookupWildcard(path)
+	if exactOK && wildOK && len(root) > len(exactRoot) {
+		exactOK = false
+	}
+
+	var c *Couple
+	var importRoot, repoRoot, subdir string
+	switch {
+	case exactOK:
+		c = exact
+		importRoot = exactRoot
+		repoRoot = c.Repo
+		subdir = c.Subdir
+	case wildOK:
+		c = wild
+		repoTemplate := c.Repo
+		if c.Hosts != nil {
+			host, ok := c.Hosts[elems[0]]
+			if !ok {
+				host, ok = c.Hosts["*"]
+			}
+			if !ok {
+				return nil
+			}
+			repoTemplate = strings.Replace(repoTemplate, HostPlaceholder, host, -1)
+			elems = elems[1:]
+		}
+		for i, elem := range elems {
+			if strings.Contains(elem, ".") {
+				switch m.WildcardDots {
+				case "reject":
+					return nil
+				case "replace":
+					elem = strings.Replace(elem, ".", m.DotReplacement, -1)
+				}
+			}
+			// A ** element matches several path elements, escaped one by one.
+			parts := strings.Split(elem, "/")
+			for j, p := range parts {
+				var ok bool
+				if parts[j], ok = repoElem(p); !ok {
+					return nil
+				}
+			}
+			elems[i] = strings.Join(parts, "/")
+		}
+		importRoot = root
+		repoRoot, elems = substituteRepo(repoTemplate, elems)
+		subdir, _ = substituteRepo(c.Subdir, elems)
+	default:
+		return nil
+	}
+	if c.Disabled {
+		return nil
+	}
+	vcs := m.VCSFor(importRoot, c)
+	match := &Match{
+		GoImport: GoImport{
+			ImportRoot: strings.TrimSuffix(importRoot, "/"),
+			VCS:        vcs,
+			VCSRoot:    m.vcsRoot(repoRoot, vcs, c.Ref),
+			Subdir:     subdir,
+		},
+		Couple:   c,
+		Nested:   m.nested(importRoot),
+		GoSource: c.GoSource,
+	}
+	if rest := path[len(importRoot):]; rest != "" && m.DocsTarget != "module" {
+		match.Suffix = "/" + strings.TrimSuffix(rest, "/")
+	}
+	var base string
+	base, match.DocsHost = m.DocsSite(c)
+	match.DocsURL = base + "/" + match.ImportRoot + match.Suffix
+	return match
+}
+
+// nested returns the go-import tags for the enabled couples
+// without a wildcard whose import paths lie below root, in order.
+func (m *Mappings) nested(root string) []GoImport {
+	below := m.under(root)
+	var paths []string
+	for importPath, c := range below {
+		if !c.Disabled {
+			paths = append(paths, importPath)
+		}
+	}
+	sort.Strings(paths)
+	var tags []GoImport
+	for _, importPath := range paths {
+		c := below[importPath]
+		vcs := m.VCSFor(importPath, c)
+		tags = append(tags, GoImport{strings.TrimSuffix(importPath, "/"), vcs, m.vcsRoot(c.Repo, vcs, c.Ref), c.Subdir})
+	}
+	return tags
+}
+
+// vcsRoot returns the repo root of a go-import tag for the repo URL repo,
+// with any wildcards already substituted, served by vcs at ref.
+// With AppendGitSuffix, .git is appended to a git repo URL lacking it.
+func (m *Mappings) vcsRoot(repo, vcs, ref string) string {
+	if m.AppendGitSuffix && vcs == "git" {
+		repo = strings.TrimRight(repo, "/")
+		if !strings.HasSuffix(repo, ".git") {
+			repo += ".git"
+		}
+	}
+	if ref != "" {
+		repo += "#" + ref
+	}
+	return repo
+}
+
+// Placeholder stands, within an element of a repo URL template, for
+// the path element matched by the next * wildcard of the import template,
+// as in https://git.example.com/go-{name}.git.
+const Placeholder = "{name}"
+
+// HostPlaceholder stands, within a repo URL template, for the host
+// that the Hosts of the couple give for the path element matched by
+// the first wildcard of the import template, as in https://{host}/*.
+const HostPlaceholder = "{host}"
+
+// substituteRepo returns the repo URL template with its * and ** elements
+// and Placeholder tokens replaced by elems, in order, and the elems left over.
+func substituteRepo(template string, elems []string) (string, []string) {
+	parts := strings.Split(template, "/")
+	for i, p := range parts {
+		if (p == "*" || p == "**") && len(elems) > 0 {
+			parts[i], elems = elems[0], elems[1:]
+			continue
+		}
+		for strings.Contains(parts[i], Placeholder) && len(elems) > 0 {
+			parts[i], elems = strings.Replace(parts[i], Placeholder, elems[0], 1), elems[1:]
+		}
+	}
+	return strings.Join(parts, "/"), elems
+}
+
+// validPath reports whether the elements of path following the host
+// could form an import path: none may be empty or begin with a dot,
+// which also rules out . and .. elements that could otherwise
+// be used to make a wildcard couple serve a misleading repo URL.
+func validPath(path string) bool {
+	elems := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	for _, elem := range elems[1:] {
+		if elem == "" || elem[0] == '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// repoElem returns the wildcard element elem escaped for substitution into
+// a repo URL. It reports false for elements that must not be substituted
+// at all: those containing path separators or control characters,
+// and . and .., which would change the meaning of the repo path.
+func repoElem(elem string) (string, bool) {
+	if elem == "." || elem == ".." || strings.ContainsAny(elem, `/\`) {
+		return "", false
+	}
+	for _, r := range elem {
+		if r < ' ' || r == 0x7f {
+			return "", false
+		}
+	}
+	return url.PathEscape(elem), true
+}
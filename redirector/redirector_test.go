@@ -0,0 +1,368 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redirector
+
+import "testing"
+
+func TestMatchInteriorWildcard(t *testing.T) {
+	m := NewMappings()
+	m.Add("example.com/*/tools/", &Couple{Repo: "https://github.com/*/tools"})
+	m.Add("example.com/x/*/y/*/", &Couple{Repo: "https://git.example.com/*/*"})
+
+	tests := []struct {
+		path string
+		root string
+		repo string
+	}{
+		{"example.com/rsc/tools", "example.com/rsc/tools", "https://github.com/rsc/tools"},
+		{"example.com/rsc/tools/cmd/x", "example.com/rsc/tools", "https://github.com/rsc/tools"},
+		{"example.com/x/a/y/b/c", "example.com/x/a/y/b", "https://git.example.com/a/b"},
+		// Short of the wildcard, or of the literal element after it.
+		{"example.com", "", ""},
+		{"example.com/rsc", "", ""},
+		{"example.com/rsc/other", "", ""},
+		{"example.com/x/a", "", ""},
+		{"example.com/x/a/y", "", ""},
+	}
+	for _, tt := range tests {
+		match := m.Match(tt.path)
+		var root, repo string
+		if match != nil {
+			root, repo = match.ImportRoot, match.VCSRoot
+		}
+		if root != tt.root || repo != tt.repo {
+			t.Errorf("Match(%q) = %q %q, want %q %q", tt.path, root, repo, tt.root, tt.repo)
+		}
+	}
+}
+
+func TestWildcardDots(t *testing.T) {
+	tests := []struct {
+		mode string
+		repl string
+		repo string // for rsc.io/x86.v2, or "" if not found
+	}{
+		{"", "", "https://github.com/rsc/x86.v2"},
+		{"keep", "", "https://github.com/rsc/x86.v2"},
+		{"replace", "-", "https://github.com/rsc/x86-v2"},
+		{"replace", "_", "https://github.com/rsc/x86_v2"},
+		{"reject", "", ""},
+	}
+	for _, tt := range tests {
+		m := NewMappings()
+		m.WildcardDots, m.DotReplacement = tt.mode, tt.repl
+		m.Add("rsc.io/*/", &Couple{Repo: "https://github.com/rsc/*"})
+		var repo string
+		if match := m.Match("rsc.io/x86.v2/x86asm"); match != nil {
+			repo = match.VCSRoot
+			if match.ImportRoot != "rsc.io/x86.v2" {
+				t.Errorf("%s: import root %q, want the element unchanged", tt.mode, match.ImportRoot)
+			}
+		}
+		if repo != tt.repo {
+			t.Errorf("%s: repo for rsc.io/x86.v2 = %q, want %q", tt.mode, repo, tt.repo)
+		}
+		// Elements without dots are never affected.
+		if match := m.Match("rsc.io/x86"); match == nil || match.VCSRoot != "https://github.com/rsc/x86" {
+			t.Errorf("%s: rsc.io/x86 = %+v", tt.mode, match)
+		}
+	}
+}
+
+func TestWildcardElemEscaped(t *testing.T) {
+	m := NewMappings()
+	m.Add("rsc.io/*/", &Couple{Repo: "https://github.com/rsc/*"})
+
+	match := m.Match("rsc.io/a b/pkg")
+	if match == nil {
+		t.Fatal(`Match("rsc.io/a b/pkg") = nil`)
+	}
+	if match.ImportRoot != "rsc.io/a b" || match.VCSRoot != "https://github.com/rsc/a%20b" {
+		t.Errorf("element with a space: %q %q, want %q %q", match.ImportRoot, match.VCSRoot, "rsc.io/a b", "https://github.com/rsc/a%20b")
+	}
+
+	for _, path := range []string{"rsc.io/..", "rsc.io/../evil", "rsc.io/."} {
+		if match := m.Match(path); match != nil {
+			t.Errorf("Match(%q) = %q %q, want nil", path, match.ImportRoot, match.VCSRoot)
+		}
+	}
+	for _, elem := range []string{"..", ".", `a\b`, "a/b", "a\x00b", "a\nb"} {
+		if got, ok := repoElem(elem); ok {
+			t.Errorf("repoElem(%q) = %q, want rejected", elem, got)
+		}
+	}
+}
+
+func TestMatchHostWildcard(t *testing.T) {
+	m := NewMappings()
+	m.Add("*.example.com/pkg/", &Couple{Repo: "https://github.com/*/pkg"})
+	m.Add("example.com/pkg/", &Couple{Repo: "https://github.com/example/pkg"})
+	m.Add("bar.example.com/pkg/", &Couple{Repo: "https://github.com/bar-exact/pkg"})
+
+	tests := []struct {
+		path string
+		root string
+		repo string // or "" if not found
+	}{
+		{"foo.example.com/pkg", "foo.example.com/pkg", "https://github.com/foo/pkg"},
+		{"foo.example.com/pkg/sub", "foo.example.com/pkg", "https://github.com/foo/pkg"},
+		// The bare domain is not a subdomain; its own rule serves it.
+		{"example.com/pkg", "example.com/pkg", "https://github.com/example/pkg"},
+		// An exact host wins over the wildcard.
+		{"bar.example.com/pkg", "bar.example.com/pkg", "https://github.com/bar-exact/pkg"},
+		// The wildcard stands for a single label.
+		{"a.b.example.com/pkg", "", ""},
+	}
+	for _, tt := range tests {
+		var root, repo string
+		if match := m.Match(tt.path); match != nil {
+			root, repo = match.ImportRoot, match.VCSRoot
+		}
+		if root != tt.root || repo != tt.repo {
+			t.Errorf("Match(%q) = %q %q, want %q %q", tt.path, root, repo, tt.root, tt.repo)
+		}
+	}
+}
+
+func TestMatchNestedRoots(t *testing.T) {
+	m := NewMappings()
+	m.Add("example.com/foo/", &Couple{Repo: "https://github.com/ex/foo"})
+	m.Add("example.com/foo/bar/", &Couple{Repo: "https://github.com/ex/bar"})
+	m.Add("example.com/foo/bar/baz/", &Couple{Repo: "https://github.com/ex/baz"})
+	m.Add("example.com/w/*/", &Couple{Repo: "https://github.com/w/*"})
+	m.Add("example.com/w/x/y/", &Couple{Repo: "https://github.com/ex/y"})
+	m.Add("example.com/w/z/", &Couple{Repo: "https://github.com/ex/z"})
+	m.Add("example.com/foobar/", &Couple{Repo: "https://github.com/ex/foobar"})
+
+	tests := []struct {
+		path string
+		root string
+	}{
+		{"example.com/foo", "example.com/foo"},
+		{"example.com/foo/a/b", "example.com/foo"},
+		{"example.com/foo/bar", "example.com/foo/bar"},
+		{"example.com/foo/bar/a", "example.com/foo/bar"},
+		{"example.com/foo/bar/baz/a/b", "example.com/foo/bar/baz"},
+		{"example.com/foo/barx", "example.com/foo"},
+		{"example.com/foobar/a", "example.com/foobar"},
+		{"example.com/foox", ""},
+		// A longer wildcard root wins over a shorter exact one, and the other way around.
+		{"example.com/w/x", "example.com/w/x"},
+		{"example.com/w/x/y/a", "example.com/w/x/y"},
+		// Between roots of equal length, the exact one wins.
+		{"example.com/w/z/a", "example.com/w/z"},
+		{"example.com/v", ""},
+		{"example.com", ""},
+	}
+	for _, tt := range tests {
+		root := ""
+		if match := m.Match(tt.path); match != nil {
+			root = match.ImportRoot
+		}
+		if root != tt.root {
+			t.Errorf("Match(%q) root = %q, want %q", tt.path, root, tt.root)
+		}
+	}
+	if match := m.Match("example.com/w/z"); match == nil || match.VCSRoot != "https://github.com/ex/z" {
+		t.Errorf("Match(example.com/w/z) = %+v, want the exact couple", match)
+	}
+}
+
+func TestMatchGreedy(t *testing.T) {
+	m := NewMappings()
+	m.Add("example.com/**/", &Couple{Repo: "https://git.example.com/**"})
+	m.Add("example.org/d/**/", &Couple{Repo: "https://git.example.org/d/**", Depth: 2})
+
+	tests := []struct {
+		path string
+		root string
+		repo string
+		docs string
+	}{
+		{"example.com/x", "example.com/x", "https://git.example.com/x", "https://godoc.org/example.com/x"},
+		{"example.com/x/y/z", "example.com/x/y/z", "https://git.example.com/x/y/z", "https://godoc.org/example.com/x/y/z"},
+		// With a depth of 2, the elements beyond the second are a package within the repo.
+		{"example.org/d/x", "example.org/d/x", "https://git.example.org/d/x", "https://godoc.org/example.org/d/x"},
+		{"example.org/d/x/y/z", "example.org/d/x/y", "https://git.example.org/d/x/y", "https://godoc.org/example.org/d/x/y/z"},
+	}
+	for _, tt := range tests {
+		match := m.Match(tt.path)
+		if match == nil {
+			t.Errorf("Match(%q) = nil, want root %s", tt.path, tt.root)
+			continue
+		}
+		if match.ImportRoot != tt.root || match.VCSRoot != tt.repo || match.DocsURL != tt.docs {
+			t.Errorf("Match(%q) = %s %s %s, want %s %s %s", tt.path, match.ImportRoot, match.VCSRoot, match.DocsURL, tt.root, tt.repo, tt.docs)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	m := NewMappings()
+	m.SetDocsHost("pkg.go.dev")
+	m.Add("rsc.io/*/", &Couple{Repo: "https://github.com/rsc/*"})
+	m.Add("rsc.io/pdf/", &Couple{Repo: "https://github.com/rsc/pdf"})
+	m.Add("example.com/mod/v2/", &Couple{Repo: "https://github.com/ex/mod-v2"})
+
+	tests := []struct {
+		host, path string
+		tag        GoImport
+		docsURL    string // or "" if not found
+	}{
+		// Exact.
+		{"rsc.io", "/pdf", GoImport{"rsc.io/pdf", "git", "https://github.com/rsc/pdf", ""}, "https://pkg.go.dev/rsc.io/pdf"},
+		{"rsc.io", "/pdf/sub", GoImport{"rsc.io/pdf", "git", "https://github.com/rsc/pdf", ""}, "https://pkg.go.dev/rsc.io/pdf/sub"},
+		// Wildcard.
+		{"rsc.io", "/x86/x86asm", GoImport{"rsc.io/x86", "git", "https://github.com/rsc/x86", ""}, "https://pkg.go.dev/rsc.io/x86/x86asm"},
+		// Version suffix: a major version within the repo of its root,
+		// or configured as a root of its own.
+		{"rsc.io", "/quote/v3", GoImport{"rsc.io/quote", "git", "https://github.com/rsc/quote", ""}, "https://pkg.go.dev/rsc.io/quote/v3"},
+		{"example.com", "/mod/v2", GoImport{"example.com/mod/v2", "git", "https://github.com/ex/mod-v2", ""}, "https://pkg.go.dev/example.com/mod/v2"},
+		// Not found.
+		{"example.com", "/mod", GoImport{}, ""},
+		{"example.com", "/mod/v3", GoImport{}, ""},
+		{"other.io", "/x", GoImport{}, ""},
+		{"rsc.io", "/", GoImport{}, ""},
+	}
+	for _, tt := range tests {
+		tag, docsURL, ok := Resolve(tt.host, tt.path, m)
+		if ok != (tt.docsURL != "") || tag != tt.tag || docsURL != tt.docsURL {
+			t.Errorf("Resolve(%q, %q) = %v, %q, %v, want %v, %q", tt.host, tt.path, tag, docsURL, ok, tt.tag, tt.docsURL)
+		}
+	}
+}
+
+func TestWildcardDocsURL(t *testing.T) {
+	m := NewMappings()
+	m.SetDocsHost("pkg.go.dev")
+	m.Add("rsc.io/*/", &Couple{Repo: "https://github.com/rsc/*"})
+	m.Add("example.com/*/tools/", &Couple{Repo: "https://github.com/*/tools"})
+
+	tests := []struct {
+		path    string
+		suffix  string
+		docsURL string
+	}{
+		{"rsc.io/x86", "", "https://pkg.go.dev/rsc.io/x86"},
+		{"rsc.io/x86/", "", "https://pkg.go.dev/rsc.io/x86"},
+		{"rsc.io/x86/x86asm", "/x86asm", "https://pkg.go.dev/rsc.io/x86/x86asm"},
+		{"rsc.io/x86/x86asm/", "/x86asm", "https://pkg.go.dev/rsc.io/x86/x86asm"},
+		{"rsc.io/x86/x86asm/sub//", "/x86asm/sub", "https://pkg.go.dev/rsc.io/x86/x86asm/sub"},
+		{"example.com/rsc/tools/cmd/x/", "/cmd/x", "https://pkg.go.dev/example.com/rsc/tools/cmd/x"},
+	}
+	for _, tt := range tests {
+		match := m.Match(tt.path)
+		if match == nil {
+			t.Errorf("Match(%q) = nil", tt.path)
+			continue
+		}
+		if match.Suffix != tt.suffix || match.DocsURL != tt.docsURL {
+			t.Errorf("Match(%q): suffix %q, docs %q, want %q, %q", tt.path, match.Suffix, match.DocsURL, tt.suffix, tt.docsURL)
+		}
+	}
+}
+
+func TestMatchSegmentBoundary(t *testing.T) {
+	m := NewMappings()
+	m.Add("rsc.io/x/", &Couple{Repo: "https://github.com/rsc/x"})
+	m.Add("example.com/*/", &Couple{Repo: "https://github.com/example/*"})
+	m.Add("example.com/ab/*/", &Couple{Repo: "https://github.com/ab/*"})
+
+	tests := []struct {
+		path string
+		root string // or "" if not found
+	}{
+		{"rsc.io/x", "rsc.io/x"},
+		{"rsc.io/x/y", "rsc.io/x"},
+		{"rsc.io/x/y/z", "rsc.io/x"},
+		{"rsc.io/xyz", ""},
+		{"rsc.io/x.y", ""},
+		{"rsc.io/x-y/z", ""},
+		{"example.com/abc/d", "example.com/abc"},
+		{"example.com/ab/c", "example.com/ab/c"},
+	}
+	for _, tt := range tests {
+		var root string
+		if match := m.Match(tt.path); match != nil {
+			root = match.ImportRoot
+		}
+		if root != tt.root {
+			t.Errorf("Match(%q) root = %q, want %q", tt.path, root, tt.root)
+		}
+	}
+}
+
+func TestMatchHosts(t *testing.T) {
+	m := NewMappings()
+	m.Add("example.com/*/*/", &Couple{
+		Repo:  "https://{host}/ex/*",
+		Hosts: map[string]string{"gh": "github.com", "gl": "gitlab.com/group", "*": "git.example.com"},
+	})
+	m.Add("example.org/*/*/", &Couple{
+		Repo:  "https://{host}/org/*",
+		Hosts: map[string]string{"gh": "github.com"},
+	})
+
+	tests := []struct {
+		path string
+		root string
+		repo string
+	}{
+		{"example.com/gh/x/sub", "example.com/gh/x", "https://github.com/ex/x"},
+		{"example.com/gl/y", "example.com/gl/y", "https://gitlab.com/group/ex/y"},
+		{"example.com/other/z", "example.com/other/z", "https://git.example.com/ex/z"},
+		{"example.org/gh/x", "example.org/gh/x", "https://github.com/org/x"},
+		// Without a "*" host, unlisted elements are not found.
+		{"example.org/gl/y", "", ""},
+	}
+	for _, tt := range tests {
+		match := m.Match(tt.path)
+		root, repo := "", ""
+		if match != nil {
+			root, repo = match.ImportRoot, match.VCSRoot
+		}
+		if root != tt.root || repo != tt.repo {
+			t.Errorf("Match(%q) = %q %q, want %q %q", tt.path, root, repo, tt.root, tt.repo)
+		}
+	}
+}
+
+func TestAppendGitSuffix(t *testing.T) {
+	m := NewMappings()
+	m.AppendGitSuffix = true
+	m.Add("example.com/plain/", &Couple{Repo: "https://github.com/ex/plain"})
+	m.Add("example.com/suffixed/", &Couple{Repo: "https://github.com/ex/suffixed.git"})
+	m.Add("example.com/slash/", &Couple{Repo: "https://github.com/ex/slash.git/"})
+	m.Add("example.com/bare-slash/", &Couple{Repo: "https://github.com/ex/bare-slash/"})
+	m.Add("example.com/ref/", &Couple{Repo: "https://github.com/ex/ref", Ref: "v1"})
+	m.Add("example.com/hg/", &Couple{Repo: "https://hg.example.com/hg", VCS: "hg"})
+	m.Add("example.com/w/*/", &Couple{Repo: "https://github.com/w/*"})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"example.com/plain", "https://github.com/ex/plain.git"},
+		{"example.com/suffixed", "https://github.com/ex/suffixed.git"},
+		{"example.com/slash", "https://github.com/ex/slash.git"},
+		{"example.com/bare-slash", "https://github.com/ex/bare-slash.git"},
+		{"example.com/ref", "https://github.com/ex/ref.git#v1"},
+		{"example.com/hg", "https://hg.example.com/hg"},
+		{"example.com/w/x/sub", "https://github.com/w/x.git"},
+	}
+	for _, tt := range tests {
+		match := m.Match(tt.path)
+		if match == nil {
+			t.Errorf("Match(%q) = nil", tt.path)
+			continue
+		}
+		if match.VCSRoot != tt.want {
+			t.Errorf("Match(%q).VCSRoot = %q, want %q", tt.path, match.VCSRoot, tt.want)
+		}
+		if want := "https://godoc.org/" + tt.path; match.DocsURL != want {
+			t.Errorf("Match(%q).DocsURL = %q, want %q", tt.path, match.DocsURL, want)
+		}
+	}
+}
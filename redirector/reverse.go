@@ -0,0 +1,174 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redirector
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Reverse returns the import root served with the repo URL repo,
+// such as rsc.io/x86 for https://github.com/rsc/x86.git.
+// Repo URLs are compared without any ref fragment, trailing slash,
+// or .git suffix. The repo is matched against the couples without a
+// wildcard first, then against the repo templates of wildcard couples,
+// whose wildcards are substituted back into the import template.
+// A wildcard couple matches only if Match, given the import root found,
+// serves that couple with the same repo; those whose wildcards cannot
+// be recovered from the repo, as when some only fill in the subdir
+// or the first selects the default of its Hosts, never match.
+// Of several matching import roots, the shortest is returned.
+// Reverse reports false if no enabled couple matches.
+func (m *Mappings) Reverse(repo string) (string, bool) {
+	repo = normalizeRepo(repo)
+	all := m.All()
+	var paths []string
+	for importPath, c := range all {
+		if !c.Disabled {
+			paths = append(paths, importPath)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if len(paths[i]) != len(paths[j]) {
+			return len(paths[i]) < len(paths[j])
+		}
+		return paths[i] < paths[j]
+	})
+	for _, importPath := range paths {
+		if c := all[importPath]; !IsWildcard(importPath) && normalizeRepo(c.Repo) == repo {
+			return strings.TrimSuffix(importPath, "/"), true
+		}
+	}
+	for _, importPath := range paths {
+		c := all[importPath]
+		if !IsWildcard(importPath) {
+			continue
+		}
+		for _, root := range m.reverseWildcard(importPath, c, repo) {
+			if match := m.Match(root); match != nil && match.Couple == c && normalizeRepo(match.VCSRoot) == repo {
+				return match.ImportRoot, true
+			}
+		}
+	}
+	return "", false
+}
+
+// normalizeRepo returns repo without any ref fragment, trailing slash,
+// or .git suffix, for comparison.
+func normalizeRepo(repo string) string {
+	if i := strings.Index(repo, "#"); i >= 0 {
+		repo = repo[:i]
+	}
+	return strings.TrimSuffix(strings.TrimRight(repo, "/"), ".git")
+}
+
+// reverseWildcard returns the import roots that the wildcard couple c
+// for the import template importPath might serve with the normalized
+// repo URL repo, one for each of its hosts that could supply the host.
+func (m *Mappings) reverseWildcard(importPath string, c *Couple, repo string) []string {
+	template := normalizeRepo(c.Repo)
+	if c.Hosts == nil {
+		if elems, ok := m.matchRepoTemplate(template, repo); ok {
+			if root, ok := fillImportTemplate(importPath, elems); ok {
+				return []string{root}
+			}
+		}
+		return nil
+	}
+	var roots []string
+	for elem, host := range c.Hosts {
+		if elem == "*" {
+			continue // the element it stands for is lost
+		}
+		if elems, ok := m.matchRepoTemplate(strings.Replace(template, HostPlaceholder, host, -1), repo); ok {
+			if root, ok := fillImportTemplate(importPath, append([]string{elem}, elems...)); ok {
+				roots = append(roots, root)
+			}
+		}
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+// matchRepoTemplate matches repo against the repo URL template,
+// returning the elements matched by its * and ** elements and its
+// Placeholder tokens, in order, with their escaping undone.
+func (m *Mappings) matchRepoTemplate(template, repo string) ([]string, bool) {
+	re := m.repoTemplateRE(template)
+	if re == nil {
+		return nil, false
+	}
+	sub := re.FindStringSubmatch(repo)
+	if sub == nil {
+		return nil, false
+	}
+	elems := sub[1:]
+	for i, e := range elems {
+		var err error
+		if elems[i], err = url.PathUnescape(e); err != nil {
+			return nil, false
+		}
+	}
+	return elems, true
+}
+
+// repoTemplateRE returns the regexp matching the repo URLs of template,
+// or nil if it cannot be compiled. Each template is compiled once,
+// on first use, and kept until the couples are replaced.
+func (m *Mappings) repoTemplateRE(template string) *regexp.Regexp {
+	m.reMu.Lock()
+	defer m.reMu.Unlock()
+	if re, ok := m.repoRE[template]; ok {
+		return re
+	}
+	parts := strings.Split(template, "/")
+	for i, p := range parts {
+		switch p {
+		case "*":
+			parts[i] = `([^/]+)`
+		case "**":
+			parts[i] = `(.+)`
+		default:
+			parts[i] = strings.Replace(regexp.QuoteMeta(p), regexp.QuoteMeta(Placeholder), `([^/]+?)`, -1)
+		}
+	}
+	re, err := regexp.Compile("^" + strings.Join(parts, "/") + "$")
+	if err != nil {
+		re = nil
+	}
+	if m.repoRE == nil {
+		m.repoRE = map[string]*regexp.Regexp{}
+	}
+	m.repoRE[template] = re
+	return re
+}
+
+// fillImportTemplate returns the import template importPath with its
+// wildcards replaced by elems, in order, and without a trailing slash.
+// It reports false unless there is exactly one element for each wildcard.
+func fillImportTemplate(importPath string, elems []string) (string, bool) {
+	parts := strings.Split(strings.TrimSuffix(importPath, "/"), "/")
+	if strings.HasPrefix(parts[0], "*.") {
+		if len(elems) == 0 {
+			return "", false
+		}
+		parts[0], elems = elems[0]+parts[0][1:], elems[1:]
+	}
+	for i, p := range parts {
+		if p != "*" && p != "**" {
+			continue
+		}
+		if len(elems) == 0 {
+			return "", false
+		}
+		parts[i], elems = elems[0], elems[1:]
+	}
+	if len(elems) > 0 {
+		return "", false
+	}
+	return strings.Join(parts, "/"), true
+}
@@ -0,0 +1,70 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redirector
+
+import "testing"
+
+func TestReverse(t *testing.T) {
+	m := NewMappings()
+	m.Add("rsc.io/x86/", &Couple{Repo: "https://github.com/rsc/x86"})
+	m.Add("rsc.io/*/", &Couple{Repo: "https://github.com/rsc/*"})
+	m.Add("example.com/x/", &Couple{Repo: "https://github.com/ex/x.git#v1"})
+	m.Add("example.com/off/", &Couple{Repo: "https://github.com/ex/off", Disabled: true})
+	m.Add("*.example.org/go/", &Couple{Repo: "https://github.com/*/go"})
+	m.Add("hosted.io/*/*/", &Couple{
+		Repo:  "https://{host}/h/*",
+		Hosts: map[string]string{"gh": "github.com", "*": "git.example.com"},
+	})
+
+	tests := []struct {
+		repo string
+		root string
+	}{
+		// Exact couples, compared without .git, fragment, or trailing slash.
+		{"https://github.com/rsc/x86", "rsc.io/x86"},
+		{"https://github.com/rsc/x86.git", "rsc.io/x86"},
+		{"https://github.com/rsc/x86/", "rsc.io/x86"},
+		{"https://github.com/ex/x", "example.com/x"},
+		// Wildcard couples, with their wildcards filled in.
+		{"https://github.com/rsc/pdf", "rsc.io/pdf"},
+		{"https://github.com/rsc/pdf.git#main", "rsc.io/pdf"},
+		{"https://github.com/foo/go", "foo.example.org/go"},
+		{"https://github.com/h/y", "hosted.io/gh/y"},
+		// A repo served only through the default host cannot be recovered.
+		{"https://git.example.com/h/y", ""},
+		{"https://github.com/ex/off", ""},
+		{"https://github.com/other/x86", ""},
+	}
+	for _, tt := range tests {
+		root, ok := m.Reverse(tt.repo)
+		if ok != (tt.root != "") || root != tt.root {
+			t.Errorf("Reverse(%q) = %q, %v, want %q", tt.repo, root, ok, tt.root)
+		}
+	}
+}
+
+func TestReverseCompilesOnce(t *testing.T) {
+	m := NewMappings()
+	m.Add("rsc.io/*/", &Couple{Repo: "https://github.com/rsc/*"})
+	for i := 0; i < 3; i++ {
+		if root, ok := m.Reverse("https://github.com/rsc/pdf"); !ok || root != "rsc.io/pdf" {
+			t.Fatalf("Reverse = %q, %v, want rsc.io/pdf", root, ok)
+		}
+	}
+	if len(m.repoRE) != 1 {
+		t.Errorf("%d repo templates compiled, want 1", len(m.repoRE))
+	}
+
+	// Templates of replaced couples are dropped.
+	n := NewMappings()
+	n.Add("rsc.io/*/", &Couple{Repo: "https://git.example.com/rsc/*"})
+	m.Replace(n)
+	if root, ok := m.Reverse("https://git.example.com/rsc/pdf"); !ok || root != "rsc.io/pdf" {
+		t.Errorf("Reverse after Replace = %q, %v, want rsc.io/pdf", root, ok)
+	}
+	if _, ok := m.repoRE["https://github.com/rsc/*"]; ok || len(m.repoRE) != 1 {
+		t.Errorf("after Replace, compiled templates %v, want only the new one", m.repoRE)
+	}
+}
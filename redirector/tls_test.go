@@ -0,0 +1,101 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redirector
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// selfSigned returns a self-signed certificate for host, held in memory.
+func selfSigned(t *testing.T, host string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: host},
+		DNSNames:              []string{host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestNewServerTLSConfig(t *testing.T) {
+	m := NewMappings()
+	m.Add("rsc.io/x86/", &Couple{Repo: "https://github.com/rsc/x86"})
+	cert := selfSigned(t, "rsc.io")
+	srv := NewServer(m, &Options{
+		Logger:    new(captureLogger), // keep the failed handshake quiet
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	})
+	if srv.TLSConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("MinVersion = %#x, want TLS 1.2", srv.TLSConfig.MinVersion)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.ServeTLS(l, "", "")
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+	dial := func(cfg *tls.Config) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig: cfg,
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return new(net.Dialer).DialContext(ctx, network, l.Addr().String())
+			},
+		}}
+	}
+
+	resp, err := dial(&tls.Config{RootCAs: roots}).Get("https://rsc.io/x86?go-get=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.TLS == nil || resp.TLS.Version < tls.VersionTLS12 {
+		t.Errorf("connection state %+v, want TLS 1.2 or later", resp.TLS)
+	}
+	if resp.StatusCode != 200 || !strings.Contains(string(body), `content="rsc.io/x86 git https://github.com/rsc/x86"`) {
+		t.Errorf("over TLS: %d %q, want the page for rsc.io/x86", resp.StatusCode, body)
+	}
+
+	if _, err := dial(&tls.Config{RootCAs: roots, MaxVersion: tls.VersionTLS11}).Get("https://rsc.io/x86?go-get=1"); err == nil {
+		t.Error("TLS 1.1 handshake succeeded")
+	}
+}
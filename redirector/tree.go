@@ -0,0 +1,134 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redirector
+
+import "strings"
+
+// A pathTree indexes wildcard import templates, such as rsc.io/*/,
+// by host and then by path element, so that finding the template
+// matching a request takes time proportional to the length of the
+// request path rather than to the number of configured couples.
+type pathTree struct {
+	hosts map[string]*treeNode
+}
+
+// A treeNode is a single path element in a pathTree.
+type treeNode struct {
+	children map[string]*treeNode
+	wildcard *treeNode // child for a * element, if any
+	greedy   *treeNode // child for a final ** element, if any
+	couple   *Couple   // couple whose template ends here, if any
+}
+
+func newPathTree() *pathTree {
+	return &pathTree{hosts: map[string]*treeNode{}}
+}
+
+func newTreeNode() *treeNode {
+	return &treeNode{children: map[string]*treeNode{}}
+}
+
+// add records c under its import template.
+func (t *pathTree) add(template string, c *Couple) {
+	elems := strings.Split(strings.TrimSuffix(template, "/"), "/")
+	n := t.hosts[elems[0]]
+	if n == nil {
+		n = newTreeNode()
+		t.hosts[elems[0]] = n
+	}
+	for _, elem := range elems[1:] {
+		if elem == "**" {
+			if n.greedy == nil {
+				n.greedy = newTreeNode()
+			}
+			n = n.greedy
+			continue
+		}
+		if elem == "*" {
+			if n.wildcard == nil {
+				n.wildcard = newTreeNode()
+			}
+			n = n.wildcard
+			continue
+		}
+		child := n.children[elem]
+		if child == nil {
+			child = newTreeNode()
+			n.children[elem] = child
+		}
+		n = child
+	}
+	n.couple = c
+}
+
+// lookup finds the couple whose template matches the longest prefix of path.
+// A * in a template matches any single non-empty path element,
+// and a final ** matches all the remaining elements, or as many as
+// the couple's depth allows.
+// When two templates match the same number of elements, the one with
+// a literal element where the other has a * or ** wins, and one with
+// a * where the other has a ** wins.
+// A template whose host is *.example.com matches hosts with a single
+// additional leading label, such as foo.example.com, but only if no
+// template for the exact host matches.
+// It returns the matched prefix of path as root and, in order, the host
+// label and elements matched by the wildcards as elems; the elements
+// matched by a ** are joined with slashes.
+func (t *pathTree) lookup(path string) (root string, elems []string, c *Couple, ok bool) {
+	parts := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	if root, elems, c, ok := t.lookupHost(parts[0], parts, nil); ok {
+		return root, elems, c, true
+	}
+	if i := strings.Index(parts[0], "."); i > 0 {
+		return t.lookupHost("*"+parts[0][i:], parts, []string{parts[0][:i]})
+	}
+	return "", nil, nil, false
+}
+
+// lookupHost is like lookup, but matches parts, the elements of the path,
+// only against the templates for host. If host is a wildcard,
+// label holds the part of the path's host that it matched.
+func (t *pathTree) lookupHost(host string, parts []string, label []string) (root string, elems []string, c *Couple, ok bool) {
+	n := t.hosts[host]
+	if n == nil {
+		return "", nil, nil, false
+	}
+
+	best := 0
+	var walk func(n *treeNode, i int, wild []string)
+	walk = func(n *treeNode, i int, wild []string) {
+		if n.couple != nil && i > best {
+			best, elems, c = i, wild, n.couple
+		}
+		if i == len(parts) {
+			return
+		}
+		// The full slice expression makes each append copy wild,
+		// which is shared with the other branches of the walk.
+		if child := n.children[parts[i]]; child != nil {
+			walk(child, i+1, wild)
+		}
+		if n.wildcard != nil && parts[i] != "" {
+			walk(n.wildcard, i+1, append(wild[:len(wild):len(wild)], parts[i]))
+		}
+		// The ** is tried last, so that it only wins if it matches
+		// strictly more elements than a more specific template.
+		if g := n.greedy; g != nil && g.couple != nil {
+			k := len(parts) - i
+			if g.couple.Depth > 0 && k > g.couple.Depth {
+				k = g.couple.Depth
+			}
+			if i+k > best {
+				best, elems, c = i+k, append(wild[:len(wild):len(wild)], strings.Join(parts[i:i+k], "/")), g.couple
+			}
+		}
+	}
+	walk(n, 1, label)
+
+	if c == nil {
+		return "", nil, nil, false
+	}
+	return strings.Join(parts[:best], "/") + "/", elems, c, true
+}
@@ -0,0 +1,148 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redirector
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTreeLookup(t *testing.T) {
+	tree := newPathTree()
+	a := &Couple{Repo: "a"}
+	b := &Couple{Repo: "b"}
+	c := &Couple{Repo: "c"}
+	d := &Couple{Repo: "d"}
+	tree.add("rsc.io/*/", a)
+	tree.add("rsc.io/x/*/", b)
+	tree.add("example.com/*/tools/", c)
+	tree.add("example.com/x/tools/", d)
+
+	tests := []struct {
+		path  string
+		root  string
+		elems []string
+		c     *Couple
+	}{
+		{"rsc.io/pdf/", "rsc.io/pdf/", []string{"pdf"}, a},
+		{"rsc.io/pdf/a/b/", "rsc.io/pdf/", []string{"pdf"}, a},
+		{"rsc.io/x/", "rsc.io/x/", []string{"x"}, a},
+		{"rsc.io/x/y/z/", "rsc.io/x/y/", []string{"y"}, b},
+		{"example.com/y/tools/sub/", "example.com/y/tools/", []string{"y"}, c},
+		{"example.com/x/tools/", "example.com/x/tools/", nil, d},
+		{"example.com/y/", "", nil, nil},
+		{"rsc.io/", "", nil, nil},
+		{"other.io/x/", "", nil, nil},
+	}
+	for _, tt := range tests {
+		root, elems, c, ok := tree.lookup(tt.path)
+		if ok != (tt.c != nil) || c != tt.c || root != tt.root || !reflect.DeepEqual(elems, tt.elems) {
+			t.Errorf("lookup(%q) = %q %q %v %v, want %q %q %v", tt.path, root, elems, c, ok, tt.root, tt.elems, tt.c)
+		}
+	}
+}
+
+func TestTreeGreedyVersusLiteral(t *testing.T) {
+	m := NewMappings()
+	m.Add("example.com/**/", &Couple{Repo: "https://git.example.com/**"})
+	m.Add("example.com/a/*/", &Couple{Repo: "https://github.com/a/*"})
+	m.Add("example.com/a/b/", &Couple{Repo: "https://github.com/a/b-exact"})
+	m.Add("example.com/c/*/", &Couple{Repo: "https://github.com/c/*"})
+	m.Add("example.com/c/lit/", &Couple{Repo: "https://github.com/c/lit"})
+
+	tests := []struct {
+		path string
+		root string
+		repo string
+	}{
+		// A * at the same depth beats the **.
+		{"example.com/a/x", "example.com/a/x", "https://github.com/a/x"},
+		// A longer ** match beats a shorter * match.
+		{"example.com/a/x/y", "example.com/a/x/y", "https://git.example.com/a/x/y"},
+		// Only the ** matches.
+		{"example.com/b/x", "example.com/b/x", "https://git.example.com/b/x"},
+		// An exact couple at the same depth beats both wildcards.
+		{"example.com/a/b", "example.com/a/b", "https://github.com/a/b-exact"},
+		{"example.com/c/lit", "example.com/c/lit", "https://github.com/c/lit"},
+	}
+	for _, tt := range tests {
+		match := m.Match(tt.path)
+		if match == nil {
+			t.Errorf("Match(%q) = nil, want root %s", tt.path, tt.root)
+			continue
+		}
+		if match.ImportRoot != tt.root || match.VCSRoot != tt.repo {
+			t.Errorf("Match(%q) = %s %s, want %s %s", tt.path, match.ImportRoot, match.VCSRoot, tt.root, tt.repo)
+		}
+	}
+}
+
+func TestTreeLiteralBeatsWildcardElement(t *testing.T) {
+	tree := newPathTree()
+	star := &Couple{Repo: "star"}
+	greedy := &Couple{Repo: "greedy"}
+	literal := &Couple{Repo: "literal"}
+	tree.add("example.com/**/", greedy)
+	tree.add("example.com/*/x/", star)
+	tree.add("example.com/a/x/", literal)
+
+	for _, tt := range []struct {
+		path string
+		want *Couple
+	}{
+		{"example.com/a/x/", literal},
+		{"example.com/b/x/", star},
+		{"example.com/b/y/", greedy},
+	} {
+		_, _, c, ok := tree.lookup(tt.path)
+		if !ok || c != tt.want {
+			got := "no match"
+			if c != nil {
+				got = c.Repo
+			}
+			t.Errorf("lookup(%q) = %s, want %s", tt.path, got, tt.want.Repo)
+		}
+	}
+}
+
+func TestTreeLookupMany(t *testing.T) {
+	tree, couples := manyTemplates(1000)
+	for i, c := range couples {
+		path := fmt.Sprintf("example%d.com/p%d/elem/sub/", i%10, i)
+		root, elems, got, ok := tree.lookup(path)
+		want := fmt.Sprintf("example%d.com/p%d/elem/", i%10, i)
+		if !ok || got != c || root != want || len(elems) != 1 || elems[0] != "elem" {
+			t.Fatalf("lookup(%q) = %q %q %v, want %q [elem] %v", path, root, elems, got, want, c)
+		}
+	}
+}
+
+// manyTemplates returns a pathTree holding n wildcard templates
+// spread over ten hosts, with their couples in order.
+func manyTemplates(n int) (*pathTree, []*Couple) {
+	tree := newPathTree()
+	couples := make([]*Couple, n)
+	for i := range couples {
+		couples[i] = &Couple{Repo: fmt.Sprintf("https://github.com/p%d/*/", i)}
+		tree.add(fmt.Sprintf("example%d.com/p%d/*/", i%10, i), couples[i])
+	}
+	return tree, couples
+}
+
+func BenchmarkTreeLookup(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			tree, _ := manyTemplates(n)
+			path := fmt.Sprintf("example%d.com/p%d/elem/sub/", (n/2)%10, n/2)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, _, _, ok := tree.lookup(path); !ok {
+					b.Fatalf("lookup(%q) failed", path)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// reloading is set to 1 while a reloaded config replaces the current one.
+var reloading int32
+
+// reloadMu serializes reloads, which may be triggered both
+// by SIGHUP and through the admin API.
+var reloadMu sync.Mutex
+
+// reloadConfig rereads the config file into new mappings and, if they load
+// and validate, swaps them in for the current ones, which are otherwise kept.
+// While the swap is under way, redirect answers with 503 rather than serve
+// a page cached from the old config.
+func reloadConfig() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	m := newMappings()
+	if err := readFile(m); err != nil {
+		return err
+	}
+	if err := validateCouples(m); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&reloading, 1)
+	mappings.Replace(m)
+	pages.reset()
+	atomic.StoreInt32(&reloading, 0)
+	warnShadowed()
+	logMappingCount()
+	return nil
+}
+
+// reloadOnHangup reloads the config each time the process receives SIGHUP,
+// until ctx is done.
+func reloadOnHangup(ctx context.Context) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	defer signal.Stop(sig)
+	for {
+		select {
+		case <-sig:
+		case <-ctx.Done():
+			return
+		}
+		log.Printf("Reloading %s", filePath)
+		if err := reloadConfig(); err != nil {
+			log.Printf("reload failed, keeping the previous config: %v", err)
+		}
+	}
+}
+
+// duringReload reports whether a reload is in progress and if so
+// replies with 503 Service Unavailable, asking the client to retry shortly.
+func duringReload(w http.ResponseWriter) bool {
+	if atomic.LoadInt32(&reloading) == 0 {
+		return false
+	}
+	w.Header().Set("Retry-After", "1")
+	w.Header().Set("Cache-Control", "no-store")
+	http.Error(w, "reloading configuration", http.StatusServiceUnavailable)
+	return true
+}
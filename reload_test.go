@@ -0,0 +1,122 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/noaleibo1/go-import-redirector/redirector"
+)
+
+func TestRedirectDuringReload(t *testing.T) {
+	useMappings(t, "example.com/x https://github.com/ex/x\n")
+	atomic.StoreInt32(&reloading, 1)
+	defer atomic.StoreInt32(&reloading, 0)
+
+	w := doRequest(redirect, "GET", "http://example.com/x?go-get=1")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	useMappings(t, "example.com/old https://github.com/ex/old\n")
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(old string) { filePath = old }(filePath)
+	filePath = filepath.Join(dir, "config.txt")
+
+	resolved := func(path string) *redirector.Page {
+		d, _, _ := resolve(path)
+		return d
+	}
+	write := func(config string) {
+		if err := ioutil.WriteFile(filePath, []byte(config), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A config that fails to load leaves the current couples in place.
+	write("example.com/new https://github.com/ex/new\nexample.com/broken\n")
+	if err := reloadConfig(); err == nil {
+		t.Fatal("reloadConfig succeeded with a malformed config")
+	}
+	if resolved("example.com/old/") == nil || resolved("example.com/new/") != nil {
+		t.Fatal("failed reload changed the couples")
+	}
+
+	write("example.com/new https://github.com/ex/new\n")
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if resolved("example.com/old/") != nil || resolved("example.com/new/") == nil {
+		t.Fatal("reload did not replace the couples")
+	}
+	if atomic.LoadInt32(&reloading) != 0 {
+		t.Error("reloading still set after reload")
+	}
+}
+
+func TestRedirectWhileReadingReload(t *testing.T) {
+	useMappings(t, "example.com/x https://github.com/ex/old\n")
+	served := make(chan *httptest.ResponseRecorder, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// A request arriving while the new config is still being read
+		// is served from the current one.
+		served <- doRequest(redirect, "GET", "http://example.com/x?go-get=1")
+		io.WriteString(w, "example.com/x https://github.com/ex/new\n")
+	}))
+	defer ts.Close()
+	filePath = ts.URL + "/config.txt"
+
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	w := <-served
+	if got := goImportTag(w.Body.String(), "example.com/x"); w.Code != 200 || got != "example.com/x git https://github.com/ex/old" {
+		t.Errorf("during reload: %d with go-import tag %q, want 200 with the old repo", w.Code, got)
+	}
+	w = doRequest(redirect, "GET", "http://example.com/x?go-get=1")
+	if got := goImportTag(w.Body.String(), "example.com/x"); got != "example.com/x git https://github.com/ex/new" {
+		t.Errorf("after reload: go-import tag %q, want the new repo", got)
+	}
+}
+
+func TestReloadNewHostEndpoints(t *testing.T) {
+	useMappings(t, "example.com/old https://github.com/ex/old\n")
+	h := newHandler()
+	if err := ioutil.WriteFile(filePath, []byte("example.com/old https://github.com/ex/old\nexample.org/new https://github.com/ex/new\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	w := doRequest(h, "GET", "http://example.org/new/.status")
+	if w.Code != 200 || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("example.org/new/.status after reload: %d with Content-Type %q, want 200 with JSON", w.Code, w.Header().Get("Content-Type"))
+	}
+	w = doRequest(h, "GET", "http://example.org/new?go-get=1")
+	if got := goImportTag(w.Body.String(), "example.org/new"); got != "example.org/new git https://github.com/ex/new" {
+		t.Errorf("example.org/new after reload: go-import tag %q, want the new repo", got)
+	}
+	w = doRequest(h, "GET", "http://example.org/robots.txt")
+	if w.Code != 200 {
+		t.Errorf("example.org/robots.txt after reload: status %d, want 200", w.Code)
+	}
+}
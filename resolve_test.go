@@ -0,0 +1,83 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrintResolution(t *testing.T) {
+	useMappings(t, `
+rsc.io/* https://github.com/rsc/*
+example.com/x https://github.com/example/x
+`)
+	tests := []struct {
+		importPath string
+		want       string
+	}{
+		// The example in the package documentation.
+		{"rsc.io/x86/x86asm", "go-import: rsc.io/x86 git https://github.com/rsc/x86\ndocs: https://godoc.org/rsc.io/x86/x86asm\n"},
+		{"example.com/x", "go-import: example.com/x git https://github.com/example/x\ndocs: https://godoc.org/example.com/x\n"},
+		{"https://example.com/x/y/", "go-import: example.com/x git https://github.com/example/x\ndocs: https://godoc.org/example.com/x/y\n"},
+		{"rsc.io", "redirect: https://godoc.org/rsc.io\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := printResolution(&buf, tt.importPath); err != nil {
+			t.Errorf("printResolution(%q): %v", tt.importPath, err)
+			continue
+		}
+		if buf.String() != tt.want {
+			t.Errorf("printResolution(%q) printed\n%s\nwant\n%s", tt.importPath, buf.String(), tt.want)
+		}
+	}
+	if err := printResolution(new(bytes.Buffer), "example.com/y"); err == nil {
+		t.Error("printResolution(example.com/y) succeeded, want not found")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	useMappings(t, `
+rsc.io/* https://github.com/rsc/*
+example.com/x https://github.com/example/x
+`)
+	d, c, redirectURL := resolve("rsc.io/x86/x86asm/")
+	if d == nil || redirectURL != "" || c.Repo != "https://github.com/rsc/*" {
+		t.Fatalf("resolve(rsc.io/x86/x86asm/) = %+v, %+v, %q", d, c, redirectURL)
+	}
+	if d.ImportRoot != "rsc.io/x86" || d.VCSRoot != "https://github.com/rsc/x86" || d.Suffix != "/x86asm" {
+		t.Errorf("resolve(rsc.io/x86/x86asm/) = %+v", d)
+	}
+	d, c, _ = resolve("example.com/x/")
+	if d == nil || d.ImportRoot != "example.com/x" || d.VCSRoot != "https://github.com/example/x" || d.Suffix != "" {
+		t.Errorf("resolve(example.com/x/) = %+v", d)
+	}
+	if d, c, redirectURL = resolve("example.com/y/"); d != nil || c != nil || redirectURL != "" {
+		t.Errorf("resolve(example.com/y/) = %+v, %+v, %q, want nothing", d, c, redirectURL)
+	}
+}
+
+func TestResolveEndpoint(t *testing.T) {
+	useMappings(t, "rsc.io/* https://github.com/rsc/*\n")
+	h := newHandler()
+	tests := []struct {
+		query  string
+		status int
+		body   string
+	}{
+		{"path=rsc.io/x86/x86asm", 200, "go-import: rsc.io/x86 git https://github.com/rsc/x86\ndocs: https://godoc.org/rsc.io/x86/x86asm\n"},
+		{"path=rsc.io/x86/x86asm&format=json", 200, `{"importRoot":"rsc.io/x86","vcs":"git","repo":"https://github.com/rsc/x86","docs":"https://godoc.org/rsc.io/x86/x86asm"}` + "\n"},
+		{"path=example.com/y", 404, ""},
+		{"path=example.com/y&format=json", 404, "example.com/y: not found\n"},
+		{"", 400, "missing path parameter\n"},
+	}
+	for _, tt := range tests {
+		w := doRequest(h, "GET", "http://rsc.io/.resolve?"+tt.query)
+		if w.Code != tt.status || tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf(".resolve?%s: %d %q, want %d %q", tt.query, w.Code, w.Body.String(), tt.status, tt.body)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/noaleibo1/go-import-redirector/redirector"
+)
+
+// selfTestElem is substituted for the wildcards of an import template
+// to make an import path for selfTest to resolve.
+const selfTestElem = "selftest"
+
+// selfTest resolves an import path for each enabled couple, as a request
+// would, and renders its page with the template, returning an error naming
+// the first couple whose page cannot be rendered or has an empty go-import tag.
+func selfTest() error {
+	all := mappings.All()
+	var paths []string
+	for importPath, c := range all {
+		if !c.Disabled {
+			paths = append(paths, importPath)
+		}
+	}
+	sort.Strings(paths)
+	for _, importPath := range paths {
+		if err := selfTestCouple(importPath, all[importPath]); err != nil {
+			return fmt.Errorf("self-test: %s %s: %v", strings.TrimSuffix(importPath, "/"), all[importPath].Repo, err)
+		}
+	}
+	return nil
+}
+
+// selfTestCouple checks the page served for importPath, a configured import
+// path or template, with any wildcards replaced by selfTestElem.
+// For a couple with hosts but no default host, the first wildcard is
+// instead replaced by the first element listed.
+func selfTestCouple(importPath string, c *redirector.Couple) error {
+	first := selfTestElem
+	if _, ok := c.Hosts["*"]; c.Hosts != nil && !ok {
+		var keys []string
+		for elem := range c.Hosts {
+			keys = append(keys, elem)
+		}
+		sort.Strings(keys)
+		first = keys[0]
+	}
+	elems := strings.Split(importPath, "/")
+	if strings.HasPrefix(importPath, "*.") {
+		elems[0] = first + elems[0][1:]
+		first = selfTestElem
+	}
+	for i, e := range elems {
+		if e == "*" || e == "**" {
+			elems[i], first = first, selfTestElem
+		}
+	}
+	path := strings.Join(elems, "/")
+	d, _, redirectURL := resolve(path)
+	if d == nil {
+		if redirectURL != "" {
+			return fmt.Errorf("%s redirects to %s instead of serving a page", strings.TrimSuffix(path, "/"), redirectURL)
+		}
+		return fmt.Errorf("%s is not found", strings.TrimSuffix(path, "/"))
+	}
+	if d.ImportRoot == "" || d.VCS == "" || d.VCSRoot == "" {
+		return fmt.Errorf("%s has an incomplete go-import tag %q", strings.TrimSuffix(path, "/"), d.GoImport)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, d); err != nil {
+		return err
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`name="go-import"`)) {
+		return fmt.Errorf("%s: page has no go-import tag", strings.TrimSuffix(path, "/"))
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+
+	"github.com/noaleibo1/go-import-redirector/redirector"
+)
+
+func TestSelfTest(t *testing.T) {
+	useMappings(t, `
+rsc.io/* https://github.com/rsc/*
+*.example.com/go https://github.com/*/go
+example.com/x https://github.com/example/x
+`)
+	if err := selfTest(); err != nil {
+		t.Fatalf("selfTest of a good config: %v", err)
+	}
+
+	// An entry that slipped past validation, with no repo.
+	mappings.Add("example.com/broken/", &redirector.Couple{})
+	err := selfTest()
+	if want := `self-test: example.com/broken : example.com/broken has an incomplete go-import tag`; err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("selfTest with a broken entry = %v, want %q", err, want)
+	}
+}
+
+func TestSelfTestTemplate(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	defer func(old *template.Template) { tmpl = old }(tmpl)
+	tmpl = template.Must(template.New("").Parse(`<html><body>{{.ImportRoot}}</body></html>`))
+	err := selfTest()
+	if want := "self-test: rsc.io/x86 https://github.com/rsc/x86: rsc.io/x86: page has no go-import tag"; err == nil || err.Error() != want {
+		t.Errorf("selfTest with a template without the tag = %v, want %q", err, want)
+	}
+}
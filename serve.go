@@ -0,0 +1,263 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+	"rsc.io/letsencrypt"
+)
+
+// newServer returns a server for h with the configured timeouts and size limits,
+// logging requests to the access log, if any, and honoring forwarded
+// headers with -trust-proxy.
+// A nil h serves http.DefaultServeMux, below -base-path if set.
+func newServer(h http.Handler) *http.Server {
+	if h == nil {
+		h = underBasePath(http.DefaultServeMux)
+	}
+	if *gzipPages {
+		h = compress(h)
+	}
+	h = countRequests(blockUserAgents(limitBody(forwarded(h))))
+	if accessLog != nil {
+		h = logAccess(h)
+	}
+	return &http.Server{
+		Handler:        h,
+		ReadTimeout:    *readTimeout,
+		WriteTimeout:   *writeTimeout,
+		IdleTimeout:    *idleTimeout,
+		MaxHeaderBytes: *maxHeaderBytes,
+		ErrorLog:       requestLog,
+	}
+}
+
+// limitBody returns a handler that rejects requests with bodies larger
+// than -max-body-bytes and otherwise calls h, with the body capped in case
+// its declared length was unknown.
+func limitBody(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.ContentLength > *maxBodyBytes {
+			w.Header().Set("Connection", "close")
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		req.Body = http.MaxBytesReader(w, req.Body, *maxBodyBytes)
+		h.ServeHTTP(w, req)
+	})
+}
+
+// listen returns a listener for addr, which is either a TCP address
+// such as :http, 127.0.0.1:80, or [::1]:8080, listened on with the -net
+// network, or a Unix domain socket written unix:/path/to/socket.
+func listen(addr string) (net.Listener, error) {
+	if path := strings.TrimPrefix(addr, "unix:"); path != addr {
+		// Remove a socket left behind by an earlier run that did not exit cleanly.
+		if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(path)
+		}
+		return net.Listen("unix", path)
+	}
+	if err := checkAddr(addr); err != nil {
+		return nil, err
+	}
+	return net.Listen(*network, addr)
+}
+
+// serverListener returns the listener for the main server, which would
+// otherwise listen on addr: with -systemd, the socket passed by systemd
+// socket activation, if there is one.
+func serverListener(addr string) (net.Listener, error) {
+	if *systemd {
+		l, err := systemdListener()
+		if l != nil || err != nil {
+			return l, err
+		}
+		log.Printf("no socket passed by systemd, listening on %s", addr)
+	}
+	return listen(addr)
+}
+
+// systemdListener returns a listener for the first socket passed
+// by systemd socket activation, or nil if no socket was passed.
+// See sd_listen_fds(3).
+func systemdListener() (net.Listener, error) {
+	if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+	// Don't pass the sockets on to any child processes.
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	const firstFD = 3
+	f := os.NewFile(firstFD, "systemd socket")
+	defer f.Close()
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("using socket passed by systemd: %v", err)
+	}
+	return l, nil
+}
+
+// checkAddr checks that addr is a well-formed TCP address for listen.
+func checkAddr(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %v", addr, err)
+	}
+	if host != "" && net.ParseIP(host) == nil && strings.Contains(host, ":") {
+		return fmt.Errorf("invalid address %q: bad IPv6 address %s", addr, host)
+	}
+	return nil
+}
+
+// checkNetwork checks the -net flag.
+func checkNetwork() error {
+	switch *network {
+	case "tcp", "tcp4", "tcp6":
+		return nil
+	}
+	return fmt.Errorf("invalid -net %q: must be tcp, tcp4, or tcp6", *network)
+}
+
+// shutdownTimeout bounds the time given to requests in progress
+// to finish once a server is told to stop.
+const shutdownTimeout = 5 * time.Second
+
+// draining is set to 1 once the process has been told to stop,
+// during the -drain-delay before the servers are shut down.
+var draining int32
+
+// stopContext returns a context that is canceled when the process
+// receives SIGINT or SIGTERM, or when stop is called. After SIGTERM,
+// the context is canceled only once -drain-delay has passed, during which
+// the ping endpoint fails, so that load balancers stop sending requests
+// before the servers stop accepting them.
+func stopContext() (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case s := <-sig:
+			if s == syscall.SIGTERM && *drainDelay > 0 {
+				atomic.StoreInt32(&draining, 1)
+				log.Printf("Draining for %v before shutting down", *drainDelay)
+				select {
+				case <-time.After(*drainDelay):
+				case <-ctx.Done():
+				}
+			}
+		case <-ctx.Done():
+		}
+		signal.Stop(sig)
+		cancel()
+	}()
+	return ctx, cancel
+}
+
+// runServer calls start, which serves with srv, until serving fails
+// or ctx is done. In that case it shuts srv down, closing its listeners
+// and letting requests in progress finish, and returns nil.
+func runServer(ctx context.Context, srv *http.Server, start func() error) error {
+	errc := make(chan error, 1)
+	go func() { errc <- start() }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		srv.Close()
+	}
+	<-errc // http.ErrServerClosed
+	return nil
+}
+
+// serve serves HTTP requests on l until serving fails or ctx is done,
+// in which case it closes l, removing any socket file, and returns nil.
+func serve(ctx context.Context, l net.Listener) error {
+	srv := newServer(nil)
+	return runServer(ctx, srv, func() error { return srv.Serve(l) })
+}
+
+// serveLetsEncrypt serves HTTPS on httpsAddr using certificates obtained by m,
+// and, unless httpAddr is empty, redirects HTTP requests on httpAddr to HTTPS.
+// It is like m.Serve, but with configurable addresses and the server timeouts,
+// and stops both servers once ctx is done.
+func serveLetsEncrypt(ctx context.Context, m *letsencrypt.Manager, httpAddr, httpsAddr string) error {
+	return serveHTTPS(ctx, m.GetCertificate, http.HandlerFunc(letsencrypt.RedirectHTTP), httpAddr, httpsAddr)
+}
+
+// newAutocertManager returns the manager obtaining certificates
+// for hosts with -acme=autocert, refusing them for any other host.
+func newAutocertManager(hosts []string) *autocert.Manager {
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(*acmeCacheDir),
+		HostPolicy: autocert.HostWhitelist(hosts...),
+		Email:      *acmeEmail,
+	}
+}
+
+// serveAutocert is like serveLetsEncrypt, but obtains certificates with m,
+// which also answers ACME http-01 challenges on httpAddr.
+func serveAutocert(ctx context.Context, m *autocert.Manager, httpAddr, httpsAddr string) error {
+	return serveHTTPS(ctx, m.GetCertificate, m.HTTPHandler(nil), httpAddr, httpsAddr)
+}
+
+// serveHTTPS serves HTTPS on httpsAddr using the certificates returned by
+// getCertificate, and, unless httpAddr is empty, serves HTTP requests on
+// httpAddr with httpHandler, which redirects them to HTTPS.
+// It stops both servers once ctx is done.
+func serveHTTPS(ctx context.Context, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error), httpHandler http.Handler, httpAddr, httpsAddr string) error {
+	if httpAddr != "" {
+		l, err := listen(httpAddr)
+		if err != nil {
+			return err
+		}
+		done := make(chan bool)
+		defer func() { <-done }()
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel() // before waiting for done
+		redirectSrv := newServer(httpHandler)
+		go func() {
+			defer close(done)
+			runServer(ctx, redirectSrv, func() error { return redirectSrv.Serve(l) })
+		}()
+	}
+
+	l, err := serverListener(httpsAddr)
+	if err != nil {
+		return err
+	}
+	srv := newServer(nil)
+	srv.TLSConfig = &tls.Config{
+		GetCertificate: getCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+	return runServer(ctx, srv, func() error { return srv.ServeTLS(l, "", "") })
+}
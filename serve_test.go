@@ -0,0 +1,311 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noaleibo1/go-import-redirector/redirector"
+	"go.uber.org/goleak"
+	"rsc.io/letsencrypt"
+)
+
+func TestServeUnixSocket(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	dir, err := ioutil.TempDir("", "socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gir.sock")
+
+	l, err := listen("unix:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newServer(newHandler())
+	go srv.Serve(l)
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return new(net.Dialer).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://rsc.io/x86/x86asm?go-get=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := goImportTag(string(body), "rsc.io/x86"); resp.StatusCode != 200 || got != "rsc.io/x86 git https://github.com/rsc/x86" {
+		t.Errorf("over the socket: %d with go-import tag %q", resp.StatusCode, got)
+	}
+}
+
+func TestServerTimeouts(t *testing.T) {
+	setFlag(t, "read-timeout", "100ms")
+	setFlag(t, "write-timeout", "200ms")
+	setFlag(t, "idle-timeout", "300ms")
+	srv := newServer(nil)
+	if srv.ReadTimeout != 100*time.Millisecond || srv.WriteTimeout != 200*time.Millisecond || srv.IdleTimeout != 300*time.Millisecond {
+		t.Fatalf("newServer timeouts = %v %v %v, want the flags", srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+
+	// A client that never finishes its request is cut off.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(l)
+	defer srv.Close()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: rsc.io\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := ioutil.ReadAll(conn); err != nil {
+		t.Errorf("slow request not closed by the server: %v", err)
+	}
+}
+
+func TestServeLetsEncryptAddrs(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	busyAddr := busy.Addr().String()
+	m := new(letsencrypt.Manager)
+
+	// HTTPS is served on httpsAddr, and nothing on the HTTP address if it is empty.
+	if err := serveLetsEncrypt(context.Background(), m, "", busyAddr); err == nil || !strings.Contains(err.Error(), busyAddr) {
+		t.Errorf("serving https on busy %s: %v, want an error for that address", busyAddr, err)
+	}
+	// The HTTP redirect listener is placed on httpAddr.
+	if err := serveLetsEncrypt(context.Background(), m, busyAddr, "127.0.0.1:0"); err == nil || !strings.Contains(err.Error(), busyAddr) {
+		t.Errorf("serving http on busy %s: %v, want an error for that address", busyAddr, err)
+	}
+}
+
+func TestRequestSizeLimits(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	setFlag(t, "max-header-bytes", "1024")
+	setFlag(t, "max-body-bytes", "512")
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = newServer(newHandler())
+	ts.Start()
+	defer ts.Close()
+
+	do := func(method, header string, body string) int {
+		req, err := http.NewRequest(method, ts.URL+"/x86?go-get=1", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = "rsc.io"
+		if header != "" {
+			req.Header.Set("X-Filler", header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := do("GET", "small", ""); code != 200 {
+		t.Errorf("small request: status %d, want 200", code)
+	}
+	// The server allows some slack beyond MaxHeaderBytes.
+	if code := do("GET", strings.Repeat("x", 16<<10), ""); code != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("16kB header: status %d, want %d", code, http.StatusRequestHeaderFieldsTooLarge)
+	}
+	if code := do("POST", "", strings.Repeat("x", 1<<10)); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("1kB body: status %d, want %d", code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestCheckAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		ok   bool
+	}{
+		{":http", true},
+		{":8080", true},
+		{"127.0.0.1:80", true},
+		{"[::1]:8080", true},
+		{"[::]:https", true},
+		{"localhost:80", true},
+		{"127.0.0.1", false},
+		{"::1:8080", false},
+		{"[::1", false},
+		{"[fe80::zz]:80", false},
+	}
+	for _, tt := range tests {
+		if err := checkAddr(tt.addr); (err == nil) != tt.ok {
+			t.Errorf("checkAddr(%q) = %v, want ok %v", tt.addr, err, tt.ok)
+		}
+	}
+}
+
+func TestListenTCP(t *testing.T) {
+	l, err := listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on IPv4: %v", err)
+	}
+	l.Close()
+
+	if _, err := listen("127.0.0.1"); err == nil {
+		t.Error("listen without a port succeeded")
+	}
+
+	l, err = listen("[::1]:0")
+	if err != nil {
+		t.Skipf("no IPv6 loopback: %v", err)
+	}
+	l.Close()
+	setFlag(t, "net", "tcp4")
+	if l, err := listen("[::1]:0"); err == nil {
+		l.Close()
+		t.Error("listen on IPv6 with -net=tcp4 succeeded")
+	}
+}
+
+func TestSystemdListener(t *testing.T) {
+	t.Setenv("LISTEN_FDS", "")
+	if l, err := systemdListener(); l != nil || err != nil {
+		t.Errorf("systemdListener without LISTEN_FDS = %v, %v, want nil", l, err)
+	}
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_PID", "1")
+	if l, err := systemdListener(); l != nil || err != nil {
+		t.Errorf("systemdListener with another process's LISTEN_PID = %v, %v, want nil", l, err)
+	}
+}
+
+// TestSystemdListenerFD passes a listening socket to a child process
+// as fd 3, as systemd does, and checks that systemdListener finds it there.
+func TestSystemdListenerFD(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no socket activation on windows")
+	}
+	if addr := os.Getenv("GIR_TEST_SYSTEMD_ADDR"); addr != "" {
+		l, err := systemdListener()
+		if err != nil || l == nil {
+			t.Fatalf("systemdListener = %v, %v", l, err)
+		}
+		defer l.Close()
+		if l.Addr().String() != addr {
+			t.Fatalf("systemdListener listens on %s, want %s", l.Addr(), addr)
+		}
+		if os.Getenv("LISTEN_FDS") != "" {
+			t.Fatal("LISTEN_FDS left set for child processes")
+		}
+		return
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSystemdListenerFD$")
+	cmd.Env = append(os.Environ(), "GIR_TEST_SYSTEMD_ADDR="+l.Addr().String(), "LISTEN_FDS=1")
+	cmd.ExtraFiles = []*os.File{f}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("child process: %v\n%s", err, out)
+	}
+}
+
+// freeAddr returns a loopback address with a port nothing listens on.
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestShutdownNoLeaks(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	httpAddr, httpsAddr := freeAddr(t), freeAddr(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- serveLetsEncrypt(ctx, new(letsencrypt.Manager), httpAddr, httpsAddr)
+	}()
+
+	// Plain http to the https address is refused by the server itself,
+	// without a certificate being needed, but still opens a connection.
+	transport := &http.Transport{}
+	client := &http.Client{
+		Transport:     transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	for _, url := range []string{"http://" + httpAddr + "/x86?go-get=1", "http://" + httpsAddr + "/"} {
+		var err error
+		for i := 0; i < 50; i++ {
+			var resp *http.Response
+			if resp, err = client.Get(url); err == nil {
+				resp.Body.Close()
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+	}
+	transport.CloseIdleConnections()
+
+	cancel()
+	if err := <-errc; err != nil {
+		t.Errorf("serveLetsEncrypt: %v", err)
+	}
+	if errs := verifyRepos(ctx, map[string]*redirector.Couple{"example.com/x/": {Repo: "http://" + httpAddr + "/x"}}); len(errs) != 1 {
+		t.Errorf("verifyRepos after shutdown = %v, want one error", errs)
+	}
+}
+
+func TestAutocertHostPolicy(t *testing.T) {
+	useMappings(t, "rsc.io/* https://github.com/rsc/*\nexample.com/x https://github.com/example/x\n")
+	m := newAutocertManager(registerHandlers(http.NewServeMux()))
+	for _, host := range []string{"rsc.io", "example.com"} {
+		if err := m.HostPolicy(context.Background(), host); err != nil {
+			t.Errorf("HostPolicy(%s) = %v, want accepted", host, err)
+		}
+	}
+	for _, host := range []string{"evil.com", "www.rsc.io", "example.org"} {
+		if err := m.HostPolicy(context.Background(), host); err == nil {
+			t.Errorf("HostPolicy(%s) accepted an unconfigured host", host)
+		}
+	}
+}
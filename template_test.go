@@ -0,0 +1,87 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"html/template"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/noaleibo1/go-import-redirector/redirector"
+)
+
+func TestTemplateErrorFallback(t *testing.T) {
+	useMappings(t, "example.com/x https://github.com/ex/x\n")
+	defer func(old *template.Template) { tmpl = old }(tmpl)
+	tmpl = template.Must(template.New("broken").Parse(`<html>{{.ImportRoot.Bogus}}</html>`))
+
+	w := doRequest(redirect, "GET", "http://example.com/x?go-get=1")
+	if w.Code != 200 {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	body := w.Body.String()
+	if got := goImportTag(body, "example.com/x"); got != "example.com/x git https://github.com/ex/x" {
+		t.Errorf("fallback page go-import tag = %q in\n%s", got, body)
+	}
+	if strings.Contains(body, "<html>") && !strings.Contains(body, "<!DOCTYPE html>") {
+		t.Errorf("served the partial template output:\n%s", body)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", cc)
+	}
+}
+
+func TestLoadTemplate(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		ok   bool
+	}{
+		{"plain", `<meta name="go-import" content="{{.ImportRoot}} {{.VCS}} {{.VCSRoot}}">`, true},
+		{"nested", `{{range .Nested}}<meta name="go-import" content="{{.ImportRoot}} {{.VCS}} {{.VCSRoot}}">{{end}}`, true},
+		{"unknown field", `{{.Bogus}}`, false},
+		{"unknown field in nested", `{{range .Nested}}{{.Bogus}}{{end}}`, false},
+		{"unknown field without docs", `{{if .NoDocs}}{{.Nope}}{{end}}`, false},
+		{"unknown field with docs", `{{if not .NoDocs}}{{.Nope}}{{end}}`, false},
+		{"syntax error", `{{if}}`, false},
+	}
+	for _, tt := range tests {
+		_, err := loadTemplate(writeTemp(t, "page.html", tt.text))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: loadTemplate error = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestCustomTemplate(t *testing.T) {
+	useMappings(t, "example.com/x https://github.com/ex/x\n")
+	custom, err := loadTemplate(writeTemp(t, "page.html",
+		`<html><meta name="go-import" content="{{.ImportRoot}} {{.VCS}} {{.VCSRoot}}">custom page</html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(old *template.Template) { tmpl = old }(tmpl)
+	tmpl = custom
+
+	w := doRequest(redirect, "GET", "http://example.com/x?go-get=1")
+	if w.Code != 200 || !strings.Contains(w.Body.String(), "custom page") {
+		t.Fatalf("got %d %q, want 200 with the custom page", w.Code, w.Body.String())
+	}
+	if got := goImportTag(w.Body.String(), "example.com/x"); got != "example.com/x git https://github.com/ex/x" {
+		t.Errorf("go-import tag = %q", got)
+	}
+}
+
+func TestPageMatchesLibrary(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\nrsc.io/x86/sub https://github.com/rsc/sub\n")
+	const url = "http://rsc.io/x86/x86asm?go-get=1"
+	want := doRequest(redirect, "GET", url).Body.String()
+	w := httptest.NewRecorder()
+	redirector.NewHandler(mappings, nil).ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+	if got := w.Body.String(); got != want {
+		t.Errorf("NewHandler page:\n%s\nwant the page served by redirect:\n%s", got, want)
+	}
+}
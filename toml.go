@@ -0,0 +1,48 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/BurntSushi/toml"
+	"github.com/noaleibo1/go-import-redirector/redirector"
+)
+
+// readTOML loads import couples into m from a TOML config, for example:
+//
+//	[defaults]
+//	vcs = "git"
+//
+//	[defaults.hostVCS]
+//	"example.com" = "hg"
+//
+//	[[mapping]]
+//	import = "rsc.io/*"
+//	repo = "https://github.com/rsc/*"
+//
+//	[[mapping]]
+//	import = "example.com/x"
+//	repo = "https://hg.example.com/x"
+//	docsHost = "pkg.go.dev"
+//
+// It holds the same fields as a JSON config, decoded through their toml
+// tags, with each mapping given as a [[mapping]] table and its hosts,
+// if any, as a [mapping.hosts] table. Keys not among those fields are
+// reported as errors.
+func readTOML(m *redirector.Mappings, r io.Reader) error {
+	var cfg jsonConfig
+	md, err := toml.NewDecoder(r).Decode(&cfg)
+	if err != nil {
+		return fmt.Errorf("file malformed: %v", err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return fmt.Errorf("file malformed: unknown key %s", undecoded[0])
+	}
+	return loadConfig(m, &cfg, func(i int) string {
+		return fmt.Sprintf("[[mapping]] #%d", i+1)
+	})
+}
@@ -0,0 +1,115 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/noaleibo1/go-import-redirector/redirector"
+)
+
+func TestTextAndTOMLConfigsAgree(t *testing.T) {
+	defer func(path string, m *redirector.Mappings) { filePath, mappings = path, m }(filePath, mappings)
+	const text = `
+@default-vcs hg.example.com hg
+rsc.io/* https://github.com/rsc/*
+example.com/x https://github.com/example/x#v1 docs-host=pkg.go.dev redirect-status=301 subdir=go
+example.com/off https://github.com/example/off enabled=false
+hg.example.com/y https://code.example.com/y
+`
+	const tomlConfig = `
+# The same couples as a TOML config.
+[defaults.hostVCS]
+"hg.example.com" = "hg"
+
+[[mapping]]
+import = "rsc.io/*"
+repo = "https://github.com/rsc/*"
+
+[[mapping]]
+import = "example.com/x"
+repo = 'https://github.com/example/x'
+ref = "v1"
+docsHost = "pkg.go.dev"
+redirectStatus = 301
+subdir = "go"
+
+[[mapping]]
+import = "example.com/off"
+repo = "https://github.com/example/off"
+enabled = false
+
+[[mapping]]
+import = "hg.example.com/y"
+repo = "https://code.example.com/y"
+`
+	importPaths := []string{"rsc.io/x86", "example.com/x", "hg.example.com/y"}
+	load := func(name, config string) (map[string]redirector.Couple, map[string]*redirector.Match) {
+		if err := readConfig(writeTemp(t, name, config)); err != nil {
+			t.Fatal(err)
+		}
+		matches := map[string]*redirector.Match{}
+		for _, importPath := range importPaths {
+			matches[importPath] = mappings.Match(importPath)
+		}
+		return loadedCouples(), matches
+	}
+	fromText, textMatches := load("config.txt", text)
+	fromTOML, tomlMatches := load("config.toml", tomlConfig)
+	if !reflect.DeepEqual(fromText, fromTOML) {
+		t.Errorf("text config couples:\n%+v\nTOML config couples:\n%+v", fromText, fromTOML)
+	}
+	for _, importPath := range importPaths {
+		a, b := textMatches[importPath], tomlMatches[importPath]
+		if a == nil || b == nil {
+			t.Errorf("%s: text match %v, TOML match %v", importPath, a, b)
+			continue
+		}
+		if a.GoImport != b.GoImport || a.DocsURL != b.DocsURL {
+			t.Errorf("%s: text config serves %v %s, TOML config %v %s", importPath, a.GoImport, a.DocsURL, b.GoImport, b.DocsURL)
+		}
+	}
+}
+
+func TestTOMLHosts(t *testing.T) {
+	defer func(path string, m *redirector.Mappings) { filePath, mappings = path, m }(filePath, mappings)
+	err := readConfig(writeTemp(t, "config.toml", `
+[[mapping]]
+import = "example.com/*/*"
+repo = "https://{host}/*"
+
+[mapping.hosts]
+gh = "github.com"
+"*" = "gitlab.com"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match := mappings.Match("example.com/gh/x"); match == nil || match.VCSRoot != "https://github.com/x" {
+		t.Errorf("Match(example.com/gh/x) = %+v, want repo https://github.com/x", match)
+	}
+}
+
+func TestTOMLErrors(t *testing.T) {
+	defer func(path string, m *redirector.Mappings) { filePath, mappings = path, m }(filePath, mappings)
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{"[[mapping]]\nimport = \"rsc.io/x\"\nrepo = \"https://github.com/rsc/x\"\nbogus = 1\n", "unknown key mapping.bogus"},
+		{"[defaults]\ncolour = \"red\"\n", "unknown key defaults.colour"},
+		{"[[mapping]]\nimport = \"rsc.io/x\"\nrepo = \"https://github.com/rsc/x\"\nredirectStatus = \"301\"\n", "redirectStatus"},
+		{"[[mapping]]\nimport = \"rsc.io/x\"\nrepo = \"https://github.com/rsc/x\n", "line 3"},
+		{"[[mapping]]\nimport = \"rsc.io/x\"\nrepo = \"https://github.com/rsc/x\"\n[[mapping]]\nimport = \"rsc.io/y\"\n", "[[mapping]] #2 (import \"rsc.io/y\"): missing repo"},
+	}
+	for _, tt := range tests {
+		err := readConfig(writeTemp(t, "config.toml", tt.config))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("reading TOML config %q = %v, want error containing %q", tt.config, err, tt.err)
+		}
+	}
+}
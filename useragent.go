@@ -0,0 +1,75 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// A regexpList is a flag.Value holding the patterns given by repeated flags,
+// each compiled as it is set.
+type regexpList []*regexp.Regexp
+
+func (l *regexpList) String() string {
+	var s []string
+	for _, re := range *l {
+		s = append(s, re.String())
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *regexpList) Set(v string) error {
+	re, err := regexp.Compile(v)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, re)
+	return nil
+}
+
+// matchString reports whether any pattern in l matches s.
+func (l regexpList) matchString(s string) bool {
+	for _, re := range l {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// goUserAgents are user agents sent by the go command,
+// which -block-user-agent patterns should not match.
+var goUserAgents = []string{"Go-http-client/1.1", "Go-http-client/2.0"}
+
+// warnBlockedGo logs a warning for each -block-user-agent pattern
+// that matches a user agent of the go command.
+func warnBlockedGo() {
+	for _, re := range blockedAgents {
+		for _, ua := range goUserAgents {
+			if re.MatchString(ua) {
+				log.Printf("warning: -block-user-agent %q matches the go command's user agent %s", re, ua)
+				break
+			}
+		}
+	}
+}
+
+// blockUserAgents returns a handler that answers requests whose user agent
+// matches a -block-user-agent pattern with 403 Forbidden and otherwise calls h.
+func blockUserAgents(h http.Handler) http.Handler {
+	if len(blockedAgents) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if blockedAgents.matchString(req.UserAgent()) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,44 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBlockUserAgent(t *testing.T) {
+	useMappings(t, "rsc.io/x86 https://github.com/rsc/x86\n")
+	defer func(old regexpList) { blockedAgents = old }(blockedAgents)
+	blockedAgents = nil
+	for _, pattern := range []string{"(?i)bot", "^curl/"} {
+		if err := blockedAgents.Set(pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := newHandler()
+
+	tests := []struct {
+		ua     string
+		status int
+	}{
+		{"BadBot/1.0", http.StatusForbidden},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1)", http.StatusForbidden},
+		{"curl/8.4.0", http.StatusForbidden},
+		{"Go-http-client/1.1", 200},
+		{"Mozilla/5.0 (X11; Linux x86_64)", 200},
+		{"libcurl-agent/1.0", 200},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "http://rsc.io/x86?go-get=1", nil)
+		req.Header.Set("User-Agent", tt.ua)
+		w := httptest.NewRecorder()
+		h(w, req)
+		if w.Code != tt.status {
+			t.Errorf("User-Agent %q: status %d, want %d", tt.ua, w.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/noaleibo1/go-import-redirector/redirector"
+)
+
+// A verifyMode is the value of the -verify-repos flag:
+// "" to skip verification, "warn" to log unreachable repos,
+// or "strict" to refuse to start if any are unreachable.
+// Given without a value, the flag means warn.
+type verifyMode string
+
+func (m *verifyMode) String() string { return string(*m) }
+
+func (m *verifyMode) IsBoolFlag() bool { return true }
+
+func (m *verifyMode) Set(v string) error {
+	switch v {
+	case "true", "warn":
+		*m = "warn"
+	case "false", "":
+		*m = ""
+	case "strict":
+		*m = "strict"
+	default:
+		return fmt.Errorf("must be warn or strict")
+	}
+	return nil
+}
+
+// verifyTimeout bounds each request made by verifyRepos.
+const verifyTimeout = 10 * time.Second
+
+// verifyRepos fetches the repo URL of each couple without a wildcard,
+// whose element is not known until a request arrives, and returns
+// an error describing each one that cannot be fetched.
+// Repos not served over HTTP or HTTPS are skipped. Requests still
+// in progress when ctx is done fail with its error.
+func verifyRepos(ctx context.Context, couples map[string]*redirector.Couple) []error {
+	client := &http.Client{Timeout: verifyTimeout}
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+		sem  = make(chan bool, 8)
+	)
+	for importPath, c := range couples {
+		if redirector.IsWildcard(importPath) || c.Disabled {
+			continue
+		}
+		if !strings.HasPrefix(c.Repo, "https://") && !strings.HasPrefix(c.Repo, "http://") {
+			continue
+		}
+		// Wait for a slot before starting the goroutine, so that
+		// a large config does not start one per couple at once.
+		sem <- true
+		wg.Add(1)
+		go func(importPath, repo string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := verifyRepo(ctx, client, repo)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: repo %s: %v", strings.TrimSuffix(importPath, "/"), repo, err))
+				mu.Unlock()
+			}
+		}(importPath, c.Repo)
+	}
+	wg.Wait()
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errs
+}
+
+// verifyRepo reports whether repo can be fetched.
+func verifyRepo(ctx context.Context, client *http.Client, repo string) error {
+	req, err := http.NewRequest("GET", repo, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+// checkRepos runs verifyRepos as requested by -verify-repos,
+// logging a warning for each unreachable repo.
+func checkRepos(ctx context.Context) {
+	if verifyRepoMode == "" {
+		return
+	}
+	errs := verifyRepos(ctx, mappings.All())
+	for _, err := range errs {
+		log.Printf("warning: %v", err)
+	}
+	if len(errs) > 0 && verifyRepoMode == "strict" {
+		log.Fatalf("%d repos could not be verified (-verify-repos=strict)", len(errs))
+	}
+}
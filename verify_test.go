@@ -0,0 +1,76 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/noaleibo1/go-import-redirector/redirector"
+)
+
+func TestVerifyRepos(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/ok" {
+			http.NotFound(w, req)
+		}
+	}))
+	defer ts.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	couples := map[string]*redirector.Couple{
+		"example.com/ok/":      {Repo: ts.URL + "/ok"},
+		"example.com/missing/": {Repo: ts.URL + "/missing"},
+		"example.com/down/":    {Repo: down.URL + "/down"},
+		"example.com/w/*/":     {Repo: ts.URL + "/w/*"},                 // element unknown
+		"example.com/off/":     {Repo: ts.URL + "/off", Disabled: true}, // not served
+		"example.com/ssh/":     {Repo: "ssh://git@example.com/ssh"},     // not HTTP
+	}
+	errs := verifyRepos(context.Background(), couples)
+	if len(errs) != 2 {
+		t.Fatalf("verifyRepos = %v, want errors for example.com/down and example.com/missing", errs)
+	}
+	if got := errs[0].Error(); !strings.HasPrefix(got, "example.com/down: repo "+down.URL+"/down: ") {
+		t.Errorf("error for an unreachable repo = %q", got)
+	}
+	if got, want := errs[1].Error(), "example.com/missing: repo "+ts.URL+"/missing: 404 Not Found"; got != want {
+		t.Errorf("error for a missing repo = %q, want %q", got, want)
+	}
+}
+
+func TestVerifyReposBounded(t *testing.T) {
+	started, release := make(chan bool, 200), make(chan bool)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		started <- true
+		<-release
+	}))
+	defer ts.Close()
+
+	couples := map[string]*redirector.Couple{}
+	for i := 0; i < 200; i++ {
+		couples[fmt.Sprintf("example.com/p%d/", i)] = &redirector.Couple{Repo: fmt.Sprintf("%s/p%d", ts.URL, i)}
+	}
+	before := runtime.NumGoroutine()
+	done := make(chan []error)
+	go func() { done <- verifyRepos(context.Background(), couples) }()
+	for i := 0; i < 8; i++ {
+		<-started
+	}
+	// Eight requests are in flight, each with a few goroutines of its
+	// connection; the other couples must not have goroutines waiting yet.
+	if n := runtime.NumGoroutine() - before; n >= 100 {
+		t.Errorf("%d goroutines started for 8 requests in flight", n)
+	}
+	close(release)
+	if errs := <-done; len(errs) != 0 {
+		t.Errorf("verifyRepos = %v, want no errors", errs)
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Build information, set at link time with
+//
+//	go build -ldflags "-X main.version=v1.0.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// as done by the Makefile.
+var (
+	version   = "devel"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// writeVersion writes the build information to w.
+func writeVersion(w io.Writer) {
+	fmt.Fprintf(w, "go-import-redirector %s (commit %s, built %s)\n", version, commit, buildDate)
+}
@@ -0,0 +1,21 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteVersion(t *testing.T) {
+	defer func(v, c, d string) { version, commit, buildDate = v, c, d }(version, commit, buildDate)
+	version, commit, buildDate = "v1.2.3", "0123abc", "2015-12-01T00:00:00Z"
+
+	var buf bytes.Buffer
+	writeVersion(&buf)
+	if got, want := buf.String(), "go-import-redirector v1.2.3 (commit 0123abc, built 2015-12-01T00:00:00Z)\n"; got != want {
+		t.Errorf("writeVersion wrote %q, want %q", got, want)
+	}
+}